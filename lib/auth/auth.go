@@ -0,0 +1,90 @@
+// Package auth contains JWT-based token verification shared by client
+// connection, private channel subscription and HTTP API authentication.
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Algorithm is a JWT signing algorithm supported by TokenVerifier
+// implementations in this package.
+type Algorithm string
+
+// Supported signing algorithms.
+const (
+	AlgorithmHS256 Algorithm = "HS256"
+	AlgorithmHS384 Algorithm = "HS384"
+	AlgorithmHS512 Algorithm = "HS512"
+	AlgorithmRS256 Algorithm = "RS256"
+	AlgorithmRS384 Algorithm = "RS384"
+	AlgorithmRS512 Algorithm = "RS512"
+	AlgorithmES256 Algorithm = "ES256"
+)
+
+// ErrInvalidToken is returned when a token can not be parsed, has an
+// unsupported algorithm or fails signature verification.
+var ErrInvalidToken = errors.New("auth: invalid token")
+
+// ErrTokenExpired is returned when exp/iat/nbf claim validation fails.
+var ErrTokenExpired = errors.New("auth: token expired or not valid yet")
+
+// ConnectTokenClaims are the claims Centrifugo expects in a client
+// connection token.
+type ConnectTokenClaims struct {
+	jwt.StandardClaims
+	Info interface{} `json:"info,omitempty"`
+}
+
+// SubscribeTokenClaims are the claims expected in a private channel
+// subscription token.
+type SubscribeTokenClaims struct {
+	jwt.StandardClaims
+	Client      string      `json:"client"`
+	Channel     string      `json:"channel"`
+	ChannelInfo interface{} `json:"info,omitempty"`
+}
+
+// APITokenClaims are the claims expected in an HTTP API request token.
+type APITokenClaims struct {
+	jwt.StandardClaims
+}
+
+// TokenVerifier is implemented by anything that can verify a connection,
+// subscription or API token and return its claims. Config.TokenVerifier
+// allows applications to plug in a custom implementation instead of (or
+// in addition to) the built-in JWTVerifier - for example to integrate
+// with a third-party OAuth2/OIDC provider.
+type TokenVerifier interface {
+	VerifyConnectToken(token string) (ConnectTokenClaims, error)
+	VerifySubscribeToken(token string) (SubscribeTokenClaims, error)
+	VerifyAPIToken(token string) (APITokenClaims, error)
+}
+
+// VerifierConfig configures a JWTVerifier.
+type VerifierConfig struct {
+	// HMACSecretKey is used to verify HS256/HS384/HS512 tokens.
+	HMACSecretKey string
+	// RSAPublicKey is used to verify RS256/RS384/RS512 tokens.
+	RSAPublicKey []byte
+	// ECDSAPublicKey is used to verify ES256 tokens.
+	ECDSAPublicKey []byte
+	// JWKSEndpoint, when set, makes the verifier fetch verification keys
+	// from a JWKS (JSON Web Key Set) URL instead of (or in addition to)
+	// the static keys above, selecting a key by the token's `kid` header.
+	JWKSEndpoint string
+	// JWKSRefreshInterval is how often the JWKS document is re-fetched.
+	// Defaults to DefaultJWKSRefreshInterval when zero.
+	JWKSRefreshInterval time.Duration
+	// Audience, when non-empty, is required to be present in a token's
+	// `aud` claim.
+	Audience string
+	// Issuer, when non-empty, is required to match a token's `iss` claim.
+	Issuer string
+}
+
+// DefaultJWKSRefreshInterval used when VerifierConfig.JWKSRefreshInterval
+// is not set.
+const DefaultJWKSRefreshInterval = 10 * time.Minute
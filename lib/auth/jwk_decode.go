@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"encoding/base64"
+	"math/big"
+)
+
+func base64URLDecodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func base64URLDecodeInt(s string) (int, error) {
+	n, err := base64URLDecodeBigInt(s)
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
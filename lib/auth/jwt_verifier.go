@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// JWTVerifier is the built-in TokenVerifier implementation. It supports
+// static HMAC/RSA/ECDSA keys as well as fetching verification keys from
+// a configured JWKS URL, refreshed on a timer and selected by the
+// token's `kid` header.
+type JWTVerifier struct {
+	config VerifierConfig
+
+	mu      sync.RWMutex
+	jwksSet map[string]interface{} // kid -> *rsa.PublicKey / *ecdsa.PublicKey
+
+	stop chan struct{}
+}
+
+// NewJWTVerifier creates a JWTVerifier and, if a JWKSEndpoint is
+// configured, performs the initial key fetch and starts the periodic
+// refresh goroutine.
+func NewJWTVerifier(config VerifierConfig) (*JWTVerifier, error) {
+	if config.JWKSRefreshInterval == 0 {
+		config.JWKSRefreshInterval = DefaultJWKSRefreshInterval
+	}
+	v := &JWTVerifier{
+		config:  config,
+		jwksSet: make(map[string]interface{}),
+		stop:    make(chan struct{}),
+	}
+	if config.JWKSEndpoint != "" {
+		if err := v.refreshJWKS(); err != nil {
+			return nil, err
+		}
+		go v.jwksRefreshRoutine()
+	}
+	return v, nil
+}
+
+// Close stops the background JWKS refresh goroutine, if running.
+func (v *JWTVerifier) Close() {
+	close(v.stop)
+}
+
+func (v *JWTVerifier) jwksRefreshRoutine() {
+	ticker := time.NewTicker(v.config.JWKSRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			// Keep serving the last known good key set on fetch error -
+			// a transient IdP outage must not start rejecting every
+			// client that is already connected.
+			_ = v.refreshJWKS()
+		}
+	}
+}
+
+// jwk is a single entry of a JSON Web Key Set document, as defined by
+// RFC 7517, restricted to the fields we need to build RSA/ECDSA public
+// keys.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+func (v *JWTVerifier) refreshJWKS() error {
+	resp, err := http.Get(v.config.JWKSEndpoint)
+	if err != nil {
+		return fmt.Errorf("auth: error fetching JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc jwksDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("auth: error decoding JWKS: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(doc.Keys))
+	for _, k := range doc.Keys {
+		key, err := jwkToPublicKey(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	v.mu.Lock()
+	v.jwksSet = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func jwkToPublicKey(k jwk) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return parseRSAJWK(k)
+	case "EC":
+		return parseECJWK(k)
+	default:
+		return nil, fmt.Errorf("auth: unsupported JWK key type %q", k.Kty)
+	}
+}
+
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case string(AlgorithmHS256), string(AlgorithmHS384), string(AlgorithmHS512):
+		if v.config.HMACSecretKey == "" {
+			return nil, ErrInvalidToken
+		}
+		return []byte(v.config.HMACSecretKey), nil
+	case string(AlgorithmRS256), string(AlgorithmRS384), string(AlgorithmRS512):
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, err := v.keyByKid(kid); err == nil {
+				return key, nil
+			}
+		}
+		if len(v.config.RSAPublicKey) == 0 {
+			return nil, ErrInvalidToken
+		}
+		return jwt.ParseRSAPublicKeyFromPEM(v.config.RSAPublicKey)
+	case string(AlgorithmES256):
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, err := v.keyByKid(kid); err == nil {
+				return key, nil
+			}
+		}
+		if len(v.config.ECDSAPublicKey) == 0 {
+			return nil, ErrInvalidToken
+		}
+		return jwt.ParseECPublicKeyFromPEM(v.config.ECDSAPublicKey)
+	default:
+		return nil, fmt.Errorf("auth: unsupported signing algorithm %q", token.Method.Alg())
+	}
+}
+
+func (v *JWTVerifier) keyByKid(kid string) (interface{}, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	key, ok := v.jwksSet[kid]
+	if !ok {
+		return nil, fmt.Errorf("auth: unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+func (v *JWTVerifier) validateStandardClaims(c jwt.StandardClaims) error {
+	if v.config.Audience != "" && !c.VerifyAudience(v.config.Audience, true) {
+		return ErrInvalidToken
+	}
+	if v.config.Issuer != "" && !c.VerifyIssuer(v.config.Issuer, true) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// VerifyConnectToken parses and validates a client connection token,
+// checking exp/iat/nbf along with the configured audience and issuer.
+func (v *JWTVerifier) VerifyConnectToken(tokenString string) (ConnectTokenClaims, error) {
+	var claims ConnectTokenClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, v.keyFunc)
+	if err != nil || !token.Valid {
+		return ConnectTokenClaims{}, tokenError(err)
+	}
+	if err := v.validateStandardClaims(claims.StandardClaims); err != nil {
+		return ConnectTokenClaims{}, err
+	}
+	return claims, nil
+}
+
+// VerifySubscribeToken parses and validates a private channel
+// subscription token.
+func (v *JWTVerifier) VerifySubscribeToken(tokenString string) (SubscribeTokenClaims, error) {
+	var claims SubscribeTokenClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, v.keyFunc)
+	if err != nil || !token.Valid {
+		return SubscribeTokenClaims{}, tokenError(err)
+	}
+	if err := v.validateStandardClaims(claims.StandardClaims); err != nil {
+		return SubscribeTokenClaims{}, err
+	}
+	return claims, nil
+}
+
+// VerifyAPIToken parses and validates an HTTP API request token.
+func (v *JWTVerifier) VerifyAPIToken(tokenString string) (APITokenClaims, error) {
+	var claims APITokenClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, v.keyFunc)
+	if err != nil || !token.Valid {
+		return APITokenClaims{}, tokenError(err)
+	}
+	if err := v.validateStandardClaims(claims.StandardClaims); err != nil {
+		return APITokenClaims{}, err
+	}
+	return claims, nil
+}
+
+// tokenError maps a jwt.ParseWithClaims failure to ErrTokenExpired when
+// only exp/iat/nbf validation failed, and to ErrInvalidToken otherwise.
+func tokenError(err error) error {
+	if ve, ok := err.(*jwt.ValidationError); ok {
+		timeErrors := uint32(jwt.ValidationErrorExpired | jwt.ValidationErrorIssuedAt | jwt.ValidationErrorNotValidYet)
+		if ve.Errors != 0 && ve.Errors&^timeErrors == 0 {
+			return ErrTokenExpired
+		}
+	}
+	return ErrInvalidToken
+}
+
+var _ TokenVerifier = (*JWTVerifier)(nil)
+
+func parseRSAJWK(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64URLDecodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64URLDecodeInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: n, E: e}, nil
+}
+
+func parseECJWK(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("auth: unsupported EC JWK curve %q", k.Crv)
+	}
+
+	x, err := base64URLDecodeBigInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("auth: error decoding EC JWK x coordinate: %v", err)
+	}
+	y, err := base64URLDecodeBigInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("auth: error decoding EC JWK y coordinate: %v", err)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("auth: EC JWK point is not on the declared curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,71 @@
+// Package channel contains channel-related configuration: per-channel
+// behaviour options and namespaces that group channels sharing the
+// same set of options.
+package channel
+
+// Namespace allows creating sets of channels with different channel
+// options. A channel's namespace is the part of its name before
+// Config.ChannelNamespaceBoundary.
+type Namespace struct {
+	// Name is a unique namespace name.
+	Name string
+	// Options for channels belonging to this namespace.
+	Options
+}
+
+// Options is a set of channel behaviour options, either applied
+// globally (via Config.Options) or per namespace (via Namespace.Options).
+type Options struct {
+	// Publish enables clients to publish messages into channels
+	// themselves (without going through the server API).
+	Publish bool
+	// Anonymous enables anonymous (no user ID) access to channels.
+	Anonymous bool
+	// Presence turns on presence information for channels. The client
+	// command handlers in package node do not record presence on
+	// subscribe; Engine.AddPresence is left to the caller.
+	Presence bool
+	// JoinLeave turns on join/leave messages for channels. Package node
+	// sends no join/leave messages.
+	JoinLeave bool
+	// Watch allows admin connections to watch messages in channels.
+	// Package node has no admin connections and does not read it.
+	Watch bool
+
+	// UseBinary makes Centrifugo encode history and presence payloads
+	// for this channel using the Protobuf codec instead of JSON, so a
+	// client that negotiated the `protobuf` client protocol format
+	// never has to decode a JSON-encoded history/presence response.
+	UseBinary bool
+
+	// HistorySize is a maximum number of publications to keep in
+	// channel history ring buffer. Zero disables history.
+	HistorySize int
+	// HistoryLifetime is a time in seconds since the last publication
+	// after which channel history can be removed.
+	HistoryLifetime int
+	// HistoryRecover enables message recovery on resubscribe: a client
+	// that passes a valid offset/epoch it last saw gets the
+	// publications it missed replayed from history instead of having
+	// to resync from scratch. Requires HistorySize and HistoryLifetime
+	// to be set to a non-zero value.
+	HistoryRecover bool
+	// HistoryMaxSize is an upper bound namespaces are allowed to set
+	// HistorySize to - it protects operators from a namespace config
+	// accidentally keeping an unbounded amount of history per channel.
+	// Zero means no additional limit beyond HistorySize itself.
+	HistoryMaxSize int
+
+	// PublishRateLimit is a maximum number of publish requests per
+	// second allowed for a single client connection in this namespace.
+	// Zero means no limit.
+	PublishRateLimit int
+	// PublishBurst is the token bucket burst size for PublishRateLimit,
+	// i.e. how many publishes a client can make in a single instant
+	// before being throttled to the steady PublishRateLimit rate.
+	PublishBurst int
+	// SubscribeRateLimit is a maximum number of subscribe requests per
+	// second allowed for a single client connection in this namespace.
+	// Zero means no limit.
+	SubscribeRateLimit int
+}
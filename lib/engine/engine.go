@@ -0,0 +1,156 @@
+// Package engine defines the Engine interface that decouples Node from
+// the concrete broker/presence storage it uses, and the common types
+// engine implementations exchange with the rest of Centrifugo.
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// ErrTombstone is returned by History when the requested offset has
+// already been trimmed from the history ring (it expired or was pushed
+// out by newer publications). The caller should treat this the same as
+// a non-matching epoch: fall back to a non-recovered resubscribe.
+var ErrTombstone = errTombstone("engine: requested offset is a tombstone")
+
+type errTombstone string
+
+func (e errTombstone) Error() string { return string(e) }
+
+// NewEpoch generates a fresh, unique epoch value. Implementations must
+// call this whenever they lose a channel's history (e.g. after a Redis
+// restart wiped the ring) and store it as the channel's new epoch, so a
+// client resuming with the old epoch is reliably told to resync instead
+// of silently missing the publications made while history was gone.
+func NewEpoch() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// EventHandler receives the events an Engine becomes aware of once it is
+// running: publications made to any channel (whether by this node or,
+// for a clustered engine like engineredis, another node sharing the
+// same keyspace) and inter-node control messages. Node implements this
+// interface and registers itself via SetEventHandler before calling
+// Run. Control messages, and publications received from engineredis,
+// reach it only once Run has started the engine; enginememory delivers
+// a publication from within Publish whether or not Run was called.
+type EventHandler interface {
+	// HandlePublication is called for every publication the engine
+	// becomes aware of, regardless of which node made it.
+	HandlePublication(channel string, pub *proto.Publication)
+	// HandleControl is called for every inter-node control message
+	// delivered over PublishControl, including ones published by this
+	// same node.
+	HandleControl(data []byte)
+}
+
+// Engine is responsible for message routing (publish/subscribe),
+// history storage and presence bookkeeping. The default is an
+// in-memory implementation suitable for a single node; engineredis
+// provides a Redis-backed implementation for running Centrifugo as a
+// cluster of nodes sharing state.
+type Engine interface {
+	// Name returns a human-readable name of the engine implementation,
+	// used in node info/metrics.
+	Name() string
+
+	// SetEventHandler registers the handler Run delivers publications
+	// and control messages to. It must be called before Run.
+	SetEventHandler(h EventHandler)
+
+	// Run is called once on node startup so the engine can establish
+	// connections and start any background goroutines it needs (control
+	// channel listener, pub/sub reader, etc) - after this returns,
+	// published messages start reaching the registered EventHandler.
+	Run() error
+	// Shutdown gracefully stops the engine.
+	Shutdown() error
+
+	// Publish delivers a publication to a channel. The opts control
+	// whether the publication is also added to channel history.
+	Publish(channel string, pub *proto.Publication, opts *PublishOptions) error
+	// PublishControl delivers a control (inter-node) message, sent over
+	// a channel separate from regular client channels.
+	PublishControl(data []byte) error
+
+	// Subscribe registers node interest in a channel, as listed by
+	// Channels. It does not gate delivery: publications reach the
+	// EventHandler whether or not Subscribe was called for their channel.
+	Subscribe(channel string) error
+	// Unsubscribe removes node interest in a channel.
+	Unsubscribe(channel string) error
+
+	// AddPresence sets presence info for uid in channel with the given
+	// expiration. useBinary selects the Protobuf codec (matching
+	// channel.Options.UseBinary) instead of JSON for engines that store
+	// presence as encoded bytes - enginememory ignores it since it keeps
+	// presence as live Go values.
+	AddPresence(channel string, uid string, info proto.ClientInfo, expire int, useBinary bool) error
+	// RemovePresence removes presence info for uid in channel.
+	RemovePresence(channel string, uid string) error
+	// Presence returns a map of active presence information in channel.
+	// useBinary must match whatever AddPresence used for this channel.
+	Presence(channel string, useBinary bool) (map[string]proto.ClientInfo, error)
+
+	// History returns publications kept for channel, most recent first.
+	// When filter.Since is set they come in offset order instead, oldest
+	// first, so they can be replayed as they were published.
+	History(channel string, filter HistoryFilter) (HistoryResult, error)
+
+	// Channels returns the channels this node has registered via
+	// Subscribe and not since removed via Unsubscribe. Channels that only
+	// other nodes subscribed to are not included.
+	Channels() ([]string, error)
+}
+
+// PublishOptions control how Publish stores a publication.
+type PublishOptions struct {
+	// HistorySize is a maximum number of publications to keep in channel
+	// history ring. Zero disables history for this publish.
+	HistorySize int
+	// HistoryLifetime is TTL in seconds for the channel history ring.
+	HistoryLifetime int
+	// UseBinary mirrors channel.Options.UseBinary: when true, engines
+	// that persist the publication as encoded bytes (engineredis) store
+	// it Protobuf-encoded instead of JSON-encoded. enginememory ignores
+	// it since it keeps publications as live Go values.
+	UseBinary bool
+}
+
+// HistoryFilter controls what History returns.
+type HistoryFilter struct {
+	// Limit restricts the number of returned publications, 0 means no
+	// limit (bounded by the ring size).
+	Limit int
+	// Since, when non-nil, makes History only return publications after
+	// this stream position - this is what powers message recovery.
+	Since *StreamPosition
+	// UseBinary must match whatever PublishOptions.UseBinary was used
+	// when publications were written to this channel's history, so the
+	// engine decodes them with the right codec.
+	UseBinary bool
+}
+
+// StreamPosition identifies a position in a channel's history stream.
+type StreamPosition struct {
+	// Offset is a monotonically increasing, per-channel publication
+	// index.
+	Offset uint64
+	// Epoch changes every time the engine loses its history for a
+	// channel (e.g. a Redis restart), so a client resuming from a stale
+	// offset/epoch pair can be reliably detected and told to resync.
+	Epoch string
+}
+
+// HistoryResult is returned by Engine.History.
+type HistoryResult struct {
+	Publications []*proto.Publication
+	// Position is the current stream position (latest offset/epoch),
+	// regardless of how many publications were returned.
+	Position StreamPosition
+}
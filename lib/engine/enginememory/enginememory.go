@@ -0,0 +1,224 @@
+// Package enginememory is the default engine.Engine implementation: it
+// keeps subscriptions, history and presence in process memory, which is
+// all a single Centrifugo node needs. Use engineredis instead when
+// running several nodes that must share that state.
+package enginememory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// Engine is an in-memory engine.Engine implementation.
+type Engine struct {
+	mu sync.Mutex
+
+	subs      map[string]struct{}
+	history   map[string]*historyRing
+	presence  map[string]map[string]presenceEntry
+	epochs    map[string]string
+	controlCh chan []byte
+	done      chan struct{}
+
+	handler engine.EventHandler
+}
+
+type presenceEntry struct {
+	info      proto.ClientInfo
+	expiresAt time.Time
+}
+
+// New creates an Engine.
+func New() *Engine {
+	return &Engine{
+		subs:      make(map[string]struct{}),
+		history:   make(map[string]*historyRing),
+		presence:  make(map[string]map[string]presenceEntry),
+		epochs:    make(map[string]string),
+		controlCh: make(chan []byte, 256),
+		done:      make(chan struct{}),
+	}
+}
+
+// Name implements engine.Engine.
+func (e *Engine) Name() string { return "Memory" }
+
+// SetEventHandler implements engine.Engine.
+func (e *Engine) SetEventHandler(h engine.EventHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handler = h
+}
+
+// Run implements engine.Engine: it starts the goroutine that delivers
+// control messages queued by PublishControl to the registered
+// EventHandler - an in-process engine still runs its own control loop
+// against itself, since there are no other nodes to receive from.
+func (e *Engine) Run() error {
+	go func() {
+		for {
+			select {
+			case data := <-e.controlCh:
+				e.mu.Lock()
+				h := e.handler
+				e.mu.Unlock()
+				if h != nil {
+					h.HandleControl(data)
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown implements engine.Engine.
+func (e *Engine) Shutdown() error {
+	close(e.done)
+	return nil
+}
+
+// Subscribe implements engine.Engine.
+func (e *Engine) Subscribe(channel string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subs[channel] = struct{}{}
+	return nil
+}
+
+// Unsubscribe implements engine.Engine.
+func (e *Engine) Unsubscribe(channel string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.subs, channel)
+	return nil
+}
+
+// Channels implements engine.Engine.
+func (e *Engine) Channels() ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	channels := make([]string, 0, len(e.subs))
+	for ch := range e.subs {
+		channels = append(channels, ch)
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+// PublishControl implements engine.Engine by delivering the control
+// message to this same process's control channel reader - a
+// single-node deployment is still expected to run its own ping/RPC loop
+// against itself.
+func (e *Engine) PublishControl(data []byte) error {
+	select {
+	case e.controlCh <- data:
+	default:
+		// Drop rather than block the publisher if nobody is reading -
+		// control messages are periodic pings, the next one will land.
+	}
+	return nil
+}
+
+// ControlChannel returns the channel PublishControl queues control
+// messages on. The goroutine started by Run reads from this same
+// channel and hands each message to the EventHandler, so once Run was
+// called another reader only receives the messages it wins.
+func (e *Engine) ControlChannel() <-chan []byte {
+	return e.controlCh
+}
+
+// Publish implements engine.Engine: it delivers pub to the registered
+// EventHandler immediately (there are no other nodes to wait on) and,
+// when opts requests it, also appends it to channel history.
+func (e *Engine) Publish(channel string, pub *proto.Publication, opts *engine.PublishOptions) error {
+	e.mu.Lock()
+	if opts != nil && opts.HistorySize > 0 {
+		ring, ok := e.history[channel]
+		if !ok {
+			ring = newHistoryRing(opts.HistorySize, opts.HistoryLifetime, e.epochFor(channel))
+			e.history[channel] = ring
+		}
+		ring.add(pub, opts.HistorySize, opts.HistoryLifetime)
+	}
+	h := e.handler
+	e.mu.Unlock()
+
+	if h != nil {
+		h.HandlePublication(channel, pub)
+	}
+	return nil
+}
+
+func (e *Engine) epochFor(channel string) string {
+	epoch, ok := e.epochs[channel]
+	if !ok {
+		epoch = engine.NewEpoch()
+		e.epochs[channel] = epoch
+	}
+	return epoch
+}
+
+// AddPresence implements engine.Engine. useBinary is ignored - presence
+// is kept as live Go values in memory, there is no wire codec involved.
+func (e *Engine) AddPresence(channel string, uid string, info proto.ClientInfo, expire int, useBinary bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	chanPresence, ok := e.presence[channel]
+	if !ok {
+		chanPresence = make(map[string]presenceEntry)
+		e.presence[channel] = chanPresence
+	}
+	chanPresence[uid] = presenceEntry{
+		info:      info,
+		expiresAt: time.Now().Add(time.Duration(expire) * time.Second),
+	}
+	return nil
+}
+
+// RemovePresence implements engine.Engine.
+func (e *Engine) RemovePresence(channel string, uid string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.presence[channel], uid)
+	return nil
+}
+
+// Presence implements engine.Engine. useBinary is ignored, see AddPresence.
+func (e *Engine) Presence(channel string, useBinary bool) (map[string]proto.ClientInfo, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := make(map[string]proto.ClientInfo)
+	now := time.Now()
+	for uid, entry := range e.presence[channel] {
+		if now.After(entry.expiresAt) {
+			delete(e.presence[channel], uid)
+			continue
+		}
+		result[uid] = entry.info
+	}
+	return result, nil
+}
+
+// History implements engine.Engine.
+func (e *Engine) History(channel string, filter engine.HistoryFilter) (engine.HistoryResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ring, ok := e.history[channel]
+	if !ok {
+		return engine.HistoryResult{
+			Position: engine.StreamPosition{Offset: 0, Epoch: e.epochFor(channel)},
+		}, nil
+	}
+	return ring.result(filter)
+}
+
+var _ engine.Engine = (*Engine)(nil)
@@ -0,0 +1,111 @@
+package enginememory
+
+import (
+	"time"
+
+	"github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// entry is a single ring slot: a publication together with the stream
+// offset it was published at.
+type entry struct {
+	pub       *proto.Publication
+	offset    uint64
+	expiresAt time.Time
+}
+
+// historyRing is a bounded, per-channel ring of recent publications,
+// keyed by an ever-increasing offset within a given epoch. It backs
+// Engine.History and is what message recovery replays from.
+type historyRing struct {
+	epoch      string
+	entries    []entry
+	nextOffset uint64
+}
+
+func newHistoryRing(size int, lifetime int, epoch string) *historyRing {
+	return &historyRing{
+		epoch:      epoch,
+		entries:    make([]entry, 0, size),
+		nextOffset: 0,
+	}
+}
+
+// add appends pub to the ring, trimming to size and dropping anything
+// past its lifetime.
+func (r *historyRing) add(pub *proto.Publication, size int, lifetime int) {
+	r.nextOffset++
+	r.entries = append(r.entries, entry{
+		pub:       pub,
+		offset:    r.nextOffset,
+		expiresAt: time.Now().Add(time.Duration(lifetime) * time.Second),
+	})
+
+	r.evictExpired()
+	if len(r.entries) > size {
+		r.entries = r.entries[len(r.entries)-size:]
+	}
+}
+
+func (r *historyRing) evictExpired() {
+	now := time.Now()
+	firstValid := 0
+	for i, e := range r.entries {
+		if now.After(e.expiresAt) {
+			firstValid = i + 1
+			continue
+		}
+		break
+	}
+	if firstValid > 0 {
+		r.entries = r.entries[firstValid:]
+	}
+}
+
+// result builds an engine.HistoryResult honouring filter.Since/filter.Limit.
+// It returns engine.ErrTombstone when the caller asked to recover from
+// an offset that has already been evicted from the ring.
+func (r *historyRing) result(filter engine.HistoryFilter) (engine.HistoryResult, error) {
+	r.evictExpired()
+
+	position := engine.StreamPosition{Epoch: r.epoch}
+	if len(r.entries) > 0 {
+		position.Offset = r.entries[len(r.entries)-1].offset
+	} else {
+		position.Offset = r.nextOffset
+	}
+
+	if filter.Since == nil {
+		pubs := make([]*proto.Publication, 0, len(r.entries))
+		for i := len(r.entries) - 1; i >= 0; i-- {
+			pubs = append(pubs, r.entries[i].pub)
+			if filter.Limit > 0 && len(pubs) >= filter.Limit {
+				break
+			}
+		}
+		return engine.HistoryResult{Publications: pubs, Position: position}, nil
+	}
+
+	if filter.Since.Epoch != r.epoch {
+		return engine.HistoryResult{Position: position}, nil
+	}
+	if len(r.entries) > 0 && filter.Since.Offset < r.entries[0].offset-1 {
+		return engine.HistoryResult{Position: position}, engine.ErrTombstone
+	}
+	if len(r.entries) == 0 && filter.Since.Offset < r.nextOffset {
+		// Everything after Since has expired out of the ring.
+		return engine.HistoryResult{Position: position}, engine.ErrTombstone
+	}
+
+	var pubs []*proto.Publication
+	for _, e := range r.entries {
+		if e.offset > filter.Since.Offset {
+			pubs = append(pubs, e.pub)
+		}
+		if filter.Limit > 0 && len(pubs) >= filter.Limit {
+			break
+		}
+	}
+	return engine.HistoryResult{Publications: pubs, Position: position}, nil
+}
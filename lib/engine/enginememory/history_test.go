@@ -0,0 +1,94 @@
+package enginememory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+func TestHistoryRingEvictsBeyondSize(t *testing.T) {
+	r := newHistoryRing(2, 60, "epoch1")
+	r.add(&proto.Publication{Data: []byte("1")}, 2, 60)
+	r.add(&proto.Publication{Data: []byte("2")}, 2, 60)
+	r.add(&proto.Publication{Data: []byte("3")}, 2, 60)
+
+	res, err := r.result(engine.HistoryFilter{})
+	if err != nil {
+		t.Fatalf("result returned error: %v", err)
+	}
+	if len(res.Publications) != 2 {
+		t.Fatalf("expected 2 publications after eviction, got %d", len(res.Publications))
+	}
+	if string(res.Publications[0].Data) != "3" || string(res.Publications[1].Data) != "2" {
+		t.Fatalf("expected most recent publications first, got %q, %q",
+			res.Publications[0].Data, res.Publications[1].Data)
+	}
+	if res.Position.Offset != 3 {
+		t.Fatalf("expected position offset 3, got %d", res.Position.Offset)
+	}
+}
+
+func TestHistoryRingEvictsExpired(t *testing.T) {
+	r := newHistoryRing(10, 1, "epoch1")
+	r.entries = append(r.entries, entry{
+		pub:       &proto.Publication{Data: []byte("old")},
+		offset:    1,
+		expiresAt: time.Now().Add(-time.Second),
+	})
+	r.nextOffset = 1
+	r.add(&proto.Publication{Data: []byte("new")}, 10, 60)
+
+	res, err := r.result(engine.HistoryFilter{})
+	if err != nil {
+		t.Fatalf("result returned error: %v", err)
+	}
+	if len(res.Publications) != 1 || string(res.Publications[0].Data) != "new" {
+		t.Fatalf("expected only the non-expired publication, got %+v", res.Publications)
+	}
+}
+
+func TestHistoryRingResultSince(t *testing.T) {
+	r := newHistoryRing(10, 60, "epoch1")
+	r.add(&proto.Publication{Data: []byte("1")}, 10, 60)
+	r.add(&proto.Publication{Data: []byte("2")}, 10, 60)
+	r.add(&proto.Publication{Data: []byte("3")}, 10, 60)
+
+	res, err := r.result(engine.HistoryFilter{Since: &engine.StreamPosition{Epoch: "epoch1", Offset: 1}})
+	if err != nil {
+		t.Fatalf("result returned error: %v", err)
+	}
+	if len(res.Publications) != 2 {
+		t.Fatalf("expected 2 publications since offset 1, got %d", len(res.Publications))
+	}
+	if string(res.Publications[0].Data) != "2" || string(res.Publications[1].Data) != "3" {
+		t.Fatalf("expected publications in offset order, got %q, %q",
+			res.Publications[0].Data, res.Publications[1].Data)
+	}
+}
+
+func TestHistoryRingResultSinceEpochMismatch(t *testing.T) {
+	r := newHistoryRing(10, 60, "epoch1")
+	r.add(&proto.Publication{Data: []byte("1")}, 10, 60)
+
+	res, err := r.result(engine.HistoryFilter{Since: &engine.StreamPosition{Epoch: "epoch2", Offset: 0}})
+	if err != nil {
+		t.Fatalf("expected no error on epoch mismatch, got %v", err)
+	}
+	if len(res.Publications) != 0 {
+		t.Fatalf("expected no publications on epoch mismatch, got %d", len(res.Publications))
+	}
+}
+
+func TestHistoryRingResultSinceTombstone(t *testing.T) {
+	r := newHistoryRing(2, 60, "epoch1")
+	r.add(&proto.Publication{Data: []byte("1")}, 2, 60)
+	r.add(&proto.Publication{Data: []byte("2")}, 2, 60)
+	r.add(&proto.Publication{Data: []byte("3")}, 2, 60)
+
+	_, err := r.result(engine.HistoryFilter{Since: &engine.StreamPosition{Epoch: "epoch1", Offset: 0}})
+	if err != engine.ErrTombstone {
+		t.Fatalf("expected ErrTombstone for an evicted offset, got %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+// Package engineredis is a Redis-backed engine.Engine implementation
+// that lets several Centrifugo nodes share channel subscriptions,
+// history and presence, so they can be run as a cluster. It supports
+// consistent-hash sharding across several Redis instances and HA via
+// Redis Sentinel.
+package engineredis
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	enginepkg "github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// RedisShardConfig configures a single Redis shard, either a plain
+// address or a set of Sentinel addresses for HA discovery.
+type RedisShardConfig struct {
+	// Host is the Redis server host. Ignored when SentinelAddrs is set.
+	Host string
+	// Port is the Redis server port. Ignored when SentinelAddrs is set.
+	Port int
+	// Password is the Redis AUTH password, empty if not required.
+	Password string
+	// DB is the Redis database number.
+	DB int
+	// Prefix is prepended to every Redis key this shard uses, so
+	// several Centrifugo deployments can share one Redis instance.
+	Prefix string
+
+	// SentinelAddrs is a list of `host:port` Sentinel addresses used to
+	// discover the current Redis master. When set, Host/Port are
+	// ignored and the shard is resolved through Sentinel instead.
+	SentinelAddrs []string
+	// SentinelMasterName is the name of the monitored master in
+	// Sentinel configuration, required when SentinelAddrs is set.
+	SentinelMasterName string
+
+	// ReadTimeout is a timeout on Redis connection read operations.
+	ReadTimeout time.Duration
+	// WriteTimeout is a timeout on Redis connection write operations.
+	WriteTimeout time.Duration
+
+	// PoolSize is the maximum number of idle connections kept open to
+	// this shard. Defaults to DefaultPoolSize when zero.
+	PoolSize int
+}
+
+// DefaultPoolSize used when RedisShardConfig.PoolSize is not set.
+const DefaultPoolSize = 128
+
+// EngineRedisConfig configures the Redis engine as a whole: the list of
+// shards to hash channels across plus node-to-node control settings.
+type EngineRedisConfig struct {
+	// Shards is the list of Redis shards publications and presence are
+	// distributed across by consistent hashing of the channel name.
+	Shards []RedisShardConfig
+	// ControlChannel is the Redis pub/sub channel used for inter-node
+	// control messages (ping, node info exchange, unsubscribe/disconnect
+	// RPC). Defaults to DefaultControlChannel when empty.
+	ControlChannel string
+}
+
+// DefaultControlChannel is used when EngineRedisConfig.ControlChannel
+// is not set.
+const DefaultControlChannel = "centrifugo.control"
+
+// luaAddHistoryPublish atomically appends a publication to a channel's
+// history sorted set (trimming it to size, scored and ordered by
+// stream offset), ensures the channel has an epoch (picking ARGV[4] as
+// the channel's epoch if none is stored yet - meaning history for this
+// channel was just lost or never existed, e.g. after a Redis restart
+// evicted the key), and returns the new {offset, epoch} pair, so
+// history writes and the position handed back to publishers can never
+// diverge under concurrent publishes.
+//
+// KEYS[1] - history sorted set key
+// KEYS[2] - history meta hash key (offset counter + epoch)
+// ARGV[1] - marshaled publication
+// ARGV[2] - history size
+// ARGV[3] - history lifetime in seconds
+// ARGV[4] - candidate epoch, used only if the channel has none yet
+var luaAddHistoryPublish = redis.NewScript(2, `
+local key = KEYS[1]
+local meta_key = KEYS[2]
+local payload = ARGV[1]
+local size = tonumber(ARGV[2])
+local lifetime = tonumber(ARGV[3])
+local candidate_epoch = ARGV[4]
+
+redis.call("hsetnx", meta_key, "epoch", candidate_epoch)
+local epoch = redis.call("hget", meta_key, "epoch")
+local offset = redis.call("hincrby", meta_key, "offset", 1)
+
+redis.call("zadd", key, offset, offset .. ":" .. payload)
+local count = redis.call("zcard", key)
+if count > size then
+  redis.call("zremrangebyrank", key, 0, count - size - 1)
+end
+
+if lifetime > 0 then
+  redis.call("expire", key, lifetime)
+  redis.call("expire", meta_key, lifetime)
+end
+
+return {offset, epoch}
+`)
+
+// luaAddPresence atomically sets presence info for a connection, records
+// its own expire_at in the expiration zset and moves the expiration of
+// both keys to expire_at. shard.presence skips every uid whose own
+// expire_at has passed, so a single slow client can't keep other
+// clients' presence alive forever and vice versa.
+//
+// KEYS[1] - presence hash key
+// KEYS[2] - presence expiration zset key
+// ARGV[1] - expire_at unix timestamp
+// ARGV[2] - uid
+// ARGV[3] - marshaled ClientInfo
+var luaAddPresence = redis.NewScript(2, `
+local hash_key = KEYS[1]
+local set_key = KEYS[2]
+local expire_at = ARGV[1]
+local uid = ARGV[2]
+local info = ARGV[3]
+redis.call("hset", hash_key, uid, info)
+redis.call("zadd", set_key, expire_at, uid)
+redis.call("expireat", hash_key, tonumber(expire_at))
+redis.call("expireat", set_key, tonumber(expire_at))
+return 1
+`)
+
+// Engine is a Redis-backed engine.Engine implementation.
+type Engine struct {
+	config EngineRedisConfig
+	shards []*shard
+}
+
+// New creates a Redis Engine from config. It does not connect until
+// Run is called.
+func New(config EngineRedisConfig) (*Engine, error) {
+	if len(config.Shards) == 0 {
+		return nil, errors.New("engineredis: at least one shard required")
+	}
+	if config.ControlChannel == "" {
+		config.ControlChannel = DefaultControlChannel
+	}
+	shards := make([]*shard, 0, len(config.Shards))
+	for _, sc := range config.Shards {
+		if len(sc.SentinelAddrs) > 0 && sc.SentinelMasterName == "" {
+			return nil, errors.New("engineredis: SentinelMasterName required when SentinelAddrs is set")
+		}
+		if sc.PoolSize == 0 {
+			sc.PoolSize = DefaultPoolSize
+		}
+		shards = append(shards, newShard(sc, config.ControlChannel))
+	}
+	return &Engine{config: config, shards: shards}, nil
+}
+
+// Name implements engine.Engine.
+func (e *Engine) Name() string { return "Redis" }
+
+// SetEventHandler implements engine.Engine.
+func (e *Engine) SetEventHandler(h enginepkg.EventHandler) {
+	for _, s := range e.shards {
+		s.handler = h
+	}
+}
+
+// shardIndex picks which shard a channel is routed to by hashing its
+// name, so publish/subscribe/history/presence for a given channel
+// always talk to the same shard.
+func (e *Engine) shardIndex(channel string) int {
+	if len(e.shards) == 1 {
+		return 0
+	}
+	// Jump consistent hash (Lamping & Veach) of the channel's CRC32, so
+	// adding a shard only moves about 1/n of the channels.
+	key := uint64(crc32.ChecksumIEEE([]byte(channel)))
+	var b, j int64 = -1, 0
+	for j < int64(len(e.shards)) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (e *Engine) shardFor(channel string) *shard {
+	return e.shards[e.shardIndex(channel)]
+}
+
+// Run implements engine.Engine: it connects every shard and starts its
+// control channel subscriber and its channel keyspace subscriber, so
+// publications and control messages start reaching the EventHandler
+// registered via SetEventHandler.
+func (e *Engine) Run() error {
+	for _, s := range e.shards {
+		if err := s.connect(); err != nil {
+			return err
+		}
+		s.runControlSubscriber()
+		s.runChannelSubscriber()
+	}
+	return nil
+}
+
+// Shutdown implements engine.Engine.
+func (e *Engine) Shutdown() error {
+	var firstErr error
+	for _, s := range e.shards {
+		if err := s.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Subscribe implements engine.Engine by recording node interest - the
+// actual delivery fan-out happens over Redis pub/sub, which every
+// shard already listens to unconditionally for its portion of the
+// keyspace, so this just needs to remember the channel for Channels().
+func (e *Engine) Subscribe(channel string) error {
+	return e.shardFor(channel).addChannel(channel)
+}
+
+// Unsubscribe implements engine.Engine.
+func (e *Engine) Unsubscribe(channel string) error {
+	return e.shardFor(channel).removeChannel(channel)
+}
+
+// Channels implements engine.Engine.
+func (e *Engine) Channels() ([]string, error) {
+	var channels []string
+	for _, s := range e.shards {
+		channels = append(channels, s.channels()...)
+	}
+	return channels, nil
+}
+
+// PublishControl implements engine.Engine by publishing to every
+// shard's control channel - any shard's subscriber delivers the
+// message to all nodes, but publishing on all of them keeps control
+// traffic flowing even if one shard is temporarily down.
+func (e *Engine) PublishControl(data []byte) error {
+	var firstErr error
+	for _, s := range e.shards {
+		if err := s.publishControl(data); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Publish implements engine.Engine: it always publishes the
+// publication over the channel's shard's pub/sub channel for delivery
+// JSON-encoded (clients each negotiate their own wire format at the
+// edge, independent of how the engine stores things), and additionally
+// appends it to history via the atomic add-history-and-publish Lua
+// script when opts request it - Protobuf-encoded when opts.UseBinary is
+// set, matching channel.Options.UseBinary, so a channel configured for
+// binary storage never has its history ring carrying JSON bytes.
+func (e *Engine) Publish(channel string, pub *proto.Publication, opts *enginepkg.PublishOptions) error {
+	s := e.shardFor(channel)
+
+	deliveryData, err := json.Marshal(pub)
+	if err != nil {
+		return err
+	}
+	if err := s.publishMessage(channel, deliveryData); err != nil {
+		return err
+	}
+
+	if opts != nil && opts.HistorySize > 0 {
+		historyData, err := encodePublication(pub, opts.UseBinary)
+		if err != nil {
+			return err
+		}
+		if _, err := s.addHistory(channel, historyData, opts.HistorySize, opts.HistoryLifetime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPresence implements engine.Engine. useBinary mirrors
+// channel.Options.UseBinary: when set, info is stored Protobuf-encoded
+// instead of JSON-encoded.
+func (e *Engine) AddPresence(channel string, uid string, info proto.ClientInfo, expire int, useBinary bool) error {
+	data, err := encodeClientInfo(&info, useBinary)
+	if err != nil {
+		return err
+	}
+	return e.shardFor(channel).addPresence(channel, uid, data, expire)
+}
+
+// RemovePresence implements engine.Engine.
+func (e *Engine) RemovePresence(channel string, uid string) error {
+	return e.shardFor(channel).removePresence(channel, uid)
+}
+
+// Presence implements engine.Engine. useBinary must match whatever
+// AddPresence used to store entries in this channel.
+func (e *Engine) Presence(channel string, useBinary bool) (map[string]proto.ClientInfo, error) {
+	raw, err := e.shardFor(channel).presence(channel)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]proto.ClientInfo, len(raw))
+	for uid, data := range raw {
+		info, err := decodeClientInfo(data, useBinary)
+		if err != nil {
+			continue
+		}
+		result[uid] = *info
+	}
+	return result, nil
+}
+
+// encodePublication serializes pub using the Protobuf codec when
+// useBinary is set, JSON otherwise.
+func encodePublication(pub *proto.Publication, useBinary bool) ([]byte, error) {
+	if useBinary {
+		return proto.MarshalPublication(pub)
+	}
+	return json.Marshal(pub)
+}
+
+func decodePublication(data []byte, useBinary bool) (*proto.Publication, error) {
+	if useBinary {
+		return proto.UnmarshalPublication(data)
+	}
+	var pub proto.Publication
+	if err := json.Unmarshal(data, &pub); err != nil {
+		return nil, err
+	}
+	return &pub, nil
+}
+
+// encodeClientInfo serializes info using the Protobuf codec when
+// useBinary is set, JSON otherwise.
+func encodeClientInfo(info *proto.ClientInfo, useBinary bool) ([]byte, error) {
+	if useBinary {
+		return proto.MarshalClientInfo(info)
+	}
+	return json.Marshal(info)
+}
+
+func decodeClientInfo(data []byte, useBinary bool) (*proto.ClientInfo, error) {
+	if useBinary {
+		return proto.UnmarshalClientInfo(data)
+	}
+	var info proto.ClientInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// History implements engine.Engine.
+func (e *Engine) History(channel string, filter enginepkg.HistoryFilter) (enginepkg.HistoryResult, error) {
+	return e.shardFor(channel).history(channel, filter)
+}
+
+var _ enginepkg.Engine = (*Engine)(nil)
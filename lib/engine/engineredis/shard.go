@@ -0,0 +1,426 @@
+package engineredis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	enginepkg "github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// shard wraps a single Redis connection pool (or Sentinel-resolved
+// pool) together with the channel bookkeeping and control pub/sub
+// subscription for the part of the keyspace hashed to it.
+type shard struct {
+	config         RedisShardConfig
+	controlChannel string
+
+	pool *redis.Pool
+
+	mu                 sync.Mutex
+	subscribedChannels map[string]struct{}
+
+	handler enginepkg.EventHandler
+}
+
+func newShard(config RedisShardConfig, controlChannel string) *shard {
+	return &shard{
+		config:             config,
+		controlChannel:     controlChannel,
+		subscribedChannels: make(map[string]struct{}),
+	}
+}
+
+func (s *shard) key(parts ...string) string {
+	key := s.config.Prefix
+	for _, p := range parts {
+		key += "." + p
+	}
+	return key
+}
+
+// channelKeyPrefix is the Redis key prefix every regular channel's
+// pub/sub key is built from (see publishMessage): s.key("channel", ch).
+// Stripping it off an incoming PMessage.Channel recovers the original
+// Centrifugo channel name.
+func (s *shard) channelKeyPrefix() string {
+	return s.key("channel", "")
+}
+
+// connect builds this shard's connection pool, resolving the current
+// master through Sentinel first when configured.
+func (s *shard) connect() error {
+	dial := s.dialFunc()
+	s.pool = &redis.Pool{
+		MaxIdle:     s.config.PoolSize,
+		IdleTimeout: 240 * time.Second,
+		Dial:        dial,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	return err
+}
+
+func (s *shard) close() error {
+	if s.pool == nil {
+		return nil
+	}
+	return s.pool.Close()
+}
+
+// dialFunc returns the function the pool uses to open a new connection,
+// resolving the master address via Sentinel on every dial when
+// SentinelAddrs is configured so a failover is picked up automatically.
+func (s *shard) dialFunc() func() (redis.Conn, error) {
+	return func() (redis.Conn, error) {
+		addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+		if len(s.config.SentinelAddrs) > 0 {
+			resolved, err := resolveMasterAddr(s.config.SentinelAddrs, s.config.SentinelMasterName)
+			if err != nil {
+				return nil, err
+			}
+			addr = resolved
+		}
+
+		opts := []redis.DialOption{
+			redis.DialDatabase(s.config.DB),
+		}
+		if s.config.Password != "" {
+			opts = append(opts, redis.DialPassword(s.config.Password))
+		}
+		if s.config.ReadTimeout > 0 {
+			opts = append(opts, redis.DialReadTimeout(s.config.ReadTimeout))
+		}
+		if s.config.WriteTimeout > 0 {
+			opts = append(opts, redis.DialWriteTimeout(s.config.WriteTimeout))
+		}
+		return redis.Dial("tcp", addr, opts...)
+	}
+}
+
+// resolveMasterAddr asks each configured Sentinel in turn for the
+// current master address of masterName, returning the first answer
+// that succeeds so a single unreachable Sentinel doesn't block
+// failover discovery.
+func resolveMasterAddr(sentinelAddrs []string, masterName string) (string, error) {
+	var lastErr error
+	for _, addr := range sentinelAddrs {
+		conn, err := redis.Dial("tcp", addr, redis.DialConnectTimeout(5*time.Second))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		reply, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", masterName))
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(reply) != 2 {
+			lastErr = errors.New("engineredis: unexpected SENTINEL reply")
+			continue
+		}
+		return reply[0] + ":" + reply[1], nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("engineredis: no reachable Sentinel")
+	}
+	return "", lastErr
+}
+
+func (s *shard) addChannel(channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribedChannels[channel] = struct{}{}
+	return nil
+}
+
+func (s *shard) removeChannel(channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribedChannels, channel)
+	return nil
+}
+
+func (s *shard) channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]string, 0, len(s.subscribedChannels))
+	for ch := range s.subscribedChannels {
+		result = append(result, ch)
+	}
+	return result
+}
+
+func (s *shard) publishMessage(channel string, data []byte) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PUBLISH", s.key("channel", channel), data)
+	return err
+}
+
+func (s *shard) publishControl(data []byte) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PUBLISH", s.controlChannel, data)
+	return err
+}
+
+// runControlSubscriber starts a background goroutine subscribed to this
+// shard's control channel, delivering every message received (including
+// ones this same node published) to the registered EventHandler.
+func (s *shard) runControlSubscriber() {
+	go func() {
+		conn := s.pool.Get()
+		defer conn.Close()
+
+		psc := redis.PubSubConn{Conn: conn}
+		if err := psc.Subscribe(s.controlChannel); err != nil {
+			return
+		}
+		for {
+			switch msg := psc.Receive().(type) {
+			case redis.Message:
+				if s.handler != nil {
+					s.handler.HandleControl(msg.Data)
+				}
+			case error:
+				return
+			}
+		}
+	}()
+}
+
+// runChannelSubscriber starts a background goroutine pattern-subscribed
+// to every regular channel key this shard owns (s.key("channel", "*")),
+// so publications made by any node - including this one - reach the
+// registered EventHandler. This is what actually makes Publish's
+// PUBLISH calls observable to anyone: without it, nothing ever issues
+// the matching SUBSCRIBE/PSUBSCRIBE and every publish is silently
+// dropped by Redis.
+func (s *shard) runChannelSubscriber() {
+	go func() {
+		conn := s.pool.Get()
+		defer conn.Close()
+
+		prefix := s.channelKeyPrefix()
+		psc := redis.PubSubConn{Conn: conn}
+		if err := psc.PSubscribe(prefix + "*"); err != nil {
+			return
+		}
+		for {
+			switch msg := psc.Receive().(type) {
+			case redis.Message:
+				if msg.Pattern == "" || s.handler == nil {
+					continue
+				}
+				channel := strings.TrimPrefix(msg.Channel, prefix)
+				pub, err := decodePublication(msg.Data, false)
+				if err != nil {
+					continue
+				}
+				s.handler.HandlePublication(channel, pub)
+			case error:
+				return
+			}
+		}
+	}()
+}
+
+// addHistory appends data to channel's history sorted set via the
+// atomic add-history Lua script and returns the resulting stream
+// position. See luaAddHistoryPublish for the mechanics.
+func (s *shard) addHistory(channel string, data []byte, size int, lifetime int) (enginepkg.StreamPosition, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	historyKey := s.key("history", channel)
+	metaKey := historyKey + ".meta"
+	reply, err := redis.Values(luaAddHistoryPublish.Do(conn, historyKey, metaKey, data, size, lifetime, enginepkg.NewEpoch()))
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	if len(reply) != 2 {
+		return enginepkg.StreamPosition{}, errors.New("engineredis: unexpected add-history script reply")
+	}
+	offset, err := redis.Int64(reply[0], nil)
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	epoch, err := redis.String(reply[1], nil)
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	return enginepkg.StreamPosition{Offset: uint64(offset), Epoch: epoch}, nil
+}
+
+// historyMember splits a sorted set member stored by luaAddHistoryPublish
+// (`"<offset>:<payload>"`) back into its offset and encoded payload.
+func historyMember(member []byte) (uint64, []byte, error) {
+	idx := bytes.IndexByte(member, ':')
+	if idx == -1 {
+		return 0, nil, errors.New("engineredis: malformed history entry")
+	}
+	offset, err := strconv.ParseUint(string(member[:idx]), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	return offset, member[idx+1:], nil
+}
+
+// ensurePosition reads the current stream position for channel,
+// creating a fresh epoch via HSETNX if the meta key is missing or
+// doesn't have one yet - which is exactly the case after the meta key
+// has expired or this channel has never been published to, so a client
+// resuming against a position from before the loss is reliably told
+// its epoch no longer matches instead of silently missing publications.
+func (s *shard) ensurePosition(conn redis.Conn, channel string) (enginepkg.StreamPosition, error) {
+	metaKey := s.key("history", channel) + ".meta"
+	_, err := conn.Do("HSETNX", metaKey, "epoch", enginepkg.NewEpoch())
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	reply, err := redis.Values(conn.Do("HMGET", metaKey, "epoch", "offset"))
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	epoch, err := redis.String(reply[0], nil)
+	if err != nil {
+		return enginepkg.StreamPosition{}, err
+	}
+	var offset uint64
+	if reply[1] != nil {
+		o, err := redis.Uint64(reply[1], nil)
+		if err != nil {
+			return enginepkg.StreamPosition{}, err
+		}
+		offset = o
+	}
+	return enginepkg.StreamPosition{Offset: offset, Epoch: epoch}, nil
+}
+
+func (s *shard) history(channel string, filter enginepkg.HistoryFilter) (enginepkg.HistoryResult, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	position, err := s.ensurePosition(conn, channel)
+	if err != nil {
+		return enginepkg.HistoryResult{}, err
+	}
+
+	historyKey := s.key("history", channel)
+
+	if filter.Since != nil {
+		if filter.Since.Epoch != position.Epoch {
+			return enginepkg.HistoryResult{Position: position}, nil
+		}
+
+		oldest, err := redis.Values(conn.Do("ZRANGE", historyKey, 0, 0, "WITHSCORES"))
+		if err != nil && err != redis.ErrNil {
+			return enginepkg.HistoryResult{}, err
+		}
+		if len(oldest) == 2 {
+			oldestOffset, err := redis.Int64(oldest[1], nil)
+			if err != nil {
+				return enginepkg.HistoryResult{}, err
+			}
+			if filter.Since.Offset < uint64(oldestOffset)-1 {
+				return enginepkg.HistoryResult{Position: position}, enginepkg.ErrTombstone
+			}
+		}
+
+		args := []interface{}{historyKey, "(" + strconv.FormatUint(filter.Since.Offset, 10), "+inf"}
+		if filter.Limit > 0 {
+			args = append(args, "LIMIT", 0, filter.Limit)
+		}
+		raw, err := redis.ByteSlices(conn.Do("ZRANGEBYSCORE", args...))
+		if err != nil && err != redis.ErrNil {
+			return enginepkg.HistoryResult{}, err
+		}
+		return enginepkg.HistoryResult{Publications: s.decodeMembers(raw, filter.UseBinary), Position: position}, nil
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	} else {
+		limit = limit - 1
+	}
+	raw, err := redis.ByteSlices(conn.Do("ZREVRANGE", historyKey, 0, limit))
+	if err != nil && err != redis.ErrNil {
+		return enginepkg.HistoryResult{}, err
+	}
+	return enginepkg.HistoryResult{Publications: s.decodeMembers(raw, filter.UseBinary), Position: position}, nil
+}
+
+// decodeMembers decodes every sorted set member into a publication,
+// in the order given, silently skipping anything malformed - a corrupt
+// single entry should not take down the whole history read.
+func (s *shard) decodeMembers(raw [][]byte, useBinary bool) []*proto.Publication {
+	pubs := make([]*proto.Publication, 0, len(raw))
+	for _, member := range raw {
+		_, payload, err := historyMember(member)
+		if err != nil {
+			continue
+		}
+		pub, err := decodePublication(payload, useBinary)
+		if err != nil {
+			continue
+		}
+		pubs = append(pubs, pub)
+	}
+	return pubs
+}
+
+func (s *shard) addPresence(channel string, uid string, data []byte, expire int) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	expireAt := time.Now().Add(time.Duration(expire) * time.Second).Unix()
+	_, err := luaAddPresence.Do(conn, s.key("presence", channel), s.key("presence", channel, "exp"),
+		strconv.FormatInt(expireAt, 10), uid, data)
+	return err
+}
+
+func (s *shard) removePresence(channel string, uid string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("HDEL", s.key("presence", channel), uid)
+	return err
+}
+
+func (s *shard) presence(channel string) (map[string][]byte, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+	reply, err := redis.StringMap(conn.Do("HGETALL", s.key("presence", channel)))
+	if err != nil {
+		if err == redis.ErrNil {
+			return map[string][]byte{}, nil
+		}
+		return nil, err
+	}
+	expired, err := redis.Strings(conn.Do("ZRANGEBYSCORE", s.key("presence", channel, "exp"), "-inf", time.Now().Unix()))
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+	result := make(map[string][]byte, len(reply))
+	for uid, data := range reply {
+		result[uid] = []byte(data)
+	}
+	for _, uid := range expired {
+		delete(result, uid)
+	}
+	return result, nil
+}
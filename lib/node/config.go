@@ -3,9 +3,13 @@ package node
 import (
 	"errors"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/centrifugal/centrifugo/lib/auth"
 	"github.com/centrifugal/centrifugo/lib/channel"
+	"github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/ratelimit"
 )
 
 // Config contains Application configuration options.
@@ -17,6 +21,27 @@ type Config struct {
 	// Secret is a secret key, used to generate signatures.
 	Secret string
 
+	// Engine is responsible for channel subscriptions, history and
+	// presence storage. Defaults to an in-memory implementation
+	// suitable for running a single node; set this to engineredis.Engine
+	// to run a cluster of nodes sharing state through Redis.
+	Engine engine.Engine
+
+	// TokenVerifier is used to verify JWT connection, private channel
+	// subscription and API request tokens. When nil, Node has no
+	// fallback: connects and private channel subscribes are rejected
+	// unless ClientInsecure is on. Set this to plug in a custom
+	// verifier, for example one backed by an OAuth2/OIDC identity
+	// provider's JWKS endpoint.
+	TokenVerifier auth.TokenVerifier
+
+	// RateLimiter enforces channel.Options.PublishRateLimit/
+	// SubscribeRateLimit for every client publish/subscribe. Defaults to
+	// an in-memory ratelimit.MemoryLimiter, suitable for a single node;
+	// set this to a ratelimit.RedisLimiter to enforce limits across a
+	// cluster of nodes sharing state through engineredis.
+	RateLimiter ratelimit.Limiter
+
 	// channel.Options embedded to config.
 	channel.Options
 
@@ -75,6 +100,26 @@ type Config struct {
 	// UserConnectionLimit limits number of connections from user with the
 	// same ID. 0 - unlimited.
 	UserConnectionLimit int
+	// UserSubscriptionLimit limits number of channel subscriptions a
+	// single user (summed across all of their connections) can hold at
+	// once. 0 - unlimited.
+	UserSubscriptionLimit int
+
+	// UniStreamMaxConnectionAge, when non-zero, makes server proactively
+	// close a unidirectional transport connection (SSE, HTTP-streaming,
+	// unidirectional WebSocket) once it has been open this long, forcing
+	// the client to reconnect.
+	UniStreamMaxConnectionAge time.Duration
+	// UniSSEQueueMaxSize is a maximum size in bytes of a client's pending
+	// message queue for the unidirectional SSE transport.
+	UniSSEQueueMaxSize int
+	// UniStreamQueueMaxSize is a maximum size in bytes of a client's
+	// pending message queue for the unidirectional HTTP-streaming
+	// transport.
+	UniStreamQueueMaxSize int
+	// UniWebsocketQueueMaxSize is a maximum size in bytes of a client's
+	// pending message queue for the unidirectional WebSocket transport.
+	UniWebsocketQueueMaxSize int
 
 	// PrivateChannelPrefix is a prefix in channel name which indicates that
 	// channel is private.
@@ -109,6 +154,14 @@ func (c *Config) Validate() error {
 	errPrefix := "config error: "
 	pattern := "^[-a-zA-Z0-9_]{2,}$"
 
+	if c.UserConnectionLimit < 0 || c.UserSubscriptionLimit < 0 {
+		return errors.New(errPrefix + "connection/subscription limits can not be negative")
+	}
+
+	if err := validateChannelOptions(c.Options, errPrefix); err != nil {
+		return err
+	}
+
 	var nss []string
 	for _, n := range c.Namespaces {
 		name := string(n.Name)
@@ -120,10 +173,63 @@ func (c *Config) Validate() error {
 			return errors.New(errPrefix + "namespace name must be unique")
 		}
 		nss = append(nss, name)
+		if err := validateChannelOptions(n.Options, errPrefix); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// validateChannelOptions rejects channel option combinations that can
+// never work, such as enabling history recovery without actually
+// keeping any history.
+func validateChannelOptions(o channel.Options, errPrefix string) error {
+	if o.HistoryRecover && (o.HistorySize <= 0 || o.HistoryLifetime <= 0) {
+		return errors.New(errPrefix + "HistoryRecover requires HistorySize and HistoryLifetime to be set")
+	}
+	if o.HistorySize < 0 {
+		return errors.New(errPrefix + "HistorySize can not be negative")
+	}
+	if o.HistoryLifetime < 0 {
+		return errors.New(errPrefix + "HistoryLifetime can not be negative")
+	}
+	if o.HistoryMaxSize < 0 {
+		return errors.New(errPrefix + "HistoryMaxSize can not be negative")
+	}
+	if o.HistoryMaxSize > 0 && o.HistorySize > o.HistoryMaxSize {
+		return errors.New(errPrefix + "HistorySize can not be greater than HistoryMaxSize")
+	}
+	if o.PublishRateLimit < 0 || o.PublishBurst < 0 || o.SubscribeRateLimit < 0 {
+		return errors.New(errPrefix + "rate limit values can not be negative")
+	}
+	if o.PublishRateLimit > 0 && o.PublishBurst == 0 {
+		return errors.New(errPrefix + "PublishBurst must be set when PublishRateLimit is used")
+	}
+	return nil
+}
+
+// channelNamespace extracts the namespace part of a channel name - the
+// part before ChannelNamespaceBoundary - so it can be looked up with
+// channelOpts. Returns "" (the global Options) when the channel carries
+// no namespace boundary.
+func (c *Config) channelNamespace(ch string) string {
+	if c.ChannelNamespaceBoundary == "" {
+		return ""
+	}
+	idx := strings.Index(ch, c.ChannelNamespaceBoundary)
+	if idx == -1 {
+		return ""
+	}
+	return ch[:idx]
+}
+
+// isPrivateChannel reports whether ch is a private channel - one whose
+// name starts with ChannelPrivatePrefix - meaning a subscribe to it
+// must carry a valid subscribe token instead of being allowed outright.
+func (c *Config) isPrivateChannel(ch string) bool {
+	return c.ChannelPrivatePrefix != "" && strings.HasPrefix(ch, c.ChannelPrivatePrefix)
+}
+
 // channelOpts searches for channel options for specified namespace key.
 func (c *Config) channelOpts(namespaceName string) (channel.Options, bool) {
 	if namespaceName == "" {
@@ -171,4 +277,8 @@ var DefaultConfig = &Config{
 	ClientRequestMaxSize:      65536,    // 64KB by default
 	ClientQueueMaxSize:        10485760, // 10MB by default
 	ClientChannelLimit:        128,
-}
\ No newline at end of file
+
+	UniSSEQueueMaxSize:       10485760, // 10MB by default
+	UniStreamQueueMaxSize:    10485760, // 10MB by default
+	UniWebsocketQueueMaxSize: 10485760, // 10MB by default
+}
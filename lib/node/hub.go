@@ -0,0 +1,140 @@
+package node
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// hub fans out publications to local subscribers - the WebSocket,
+// SockJS and unidirectional transport connections terminated by this
+// node. The engine is what makes a publication cross node boundaries;
+// hub is what gets it the rest of the way to an actual open connection
+// on this process.
+type hub struct {
+	mu   sync.Mutex
+	subs map[string]map[uint64]*subscriber
+
+	nextID uint64
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: make(map[string]map[uint64]*subscriber),
+	}
+}
+
+// pushItem is a single publication queued for a subscriber, paired with
+// the channel it was published to since one subscriber's queue can hold
+// publications from several bootstrapped channels at once.
+type pushItem struct {
+	channel string
+	pub     *proto.Publication
+}
+
+// subscriber buffers publications for a single local subscription,
+// bounded in bytes rather than message count so it matches the
+// Uni*QueueMaxSize/ClientQueueMaxSize config options, which are all
+// specified as a byte budget. Once that budget is exceeded the
+// subscriber is considered overflowed and its connection should be
+// closed - a slow reader must not be allowed to grow this queue
+// without bound.
+type subscriber struct {
+	mu       sync.Mutex
+	queue    []pushItem
+	bytes    int
+	maxBytes int
+	overflow bool
+	ready    chan struct{}
+}
+
+func newSubscriber(maxBytes int) *subscriber {
+	if maxBytes <= 0 {
+		maxBytes = 10485760 // 10MB, matches DefaultConfig's Uni*QueueMaxSize default.
+	}
+	return &subscriber{
+		maxBytes: maxBytes,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+func (s *subscriber) push(channel string, pub *proto.Publication) {
+	s.mu.Lock()
+	if s.overflow {
+		s.mu.Unlock()
+		return
+	}
+	if s.bytes+len(pub.Data) > s.maxBytes {
+		s.overflow = true
+		s.mu.Unlock()
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+		return
+	}
+	s.queue = append(s.queue, pushItem{channel: channel, pub: pub})
+	s.bytes += len(pub.Data)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns every publication queued so far and whether the
+// subscriber has overflowed its byte budget, in which case the caller
+// must close the connection after handling whatever drain returned.
+func (s *subscriber) drain() ([]pushItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.queue
+	s.queue = nil
+	s.bytes = 0
+	return items, s.overflow
+}
+
+// subscribe registers a single new subscriber across every one of
+// channels, sharing one queue and one ready signal between them, and
+// returns it together with an unsubscribe function the caller must call
+// once it's done with the subscription (connection closed, context
+// cancelled, etc). Sharing one subscriber across channels is what lets
+// a unidirectional transport connection bootstrap several channels at
+// once and still have a single queue to drain and a single byte budget
+// to enforce across all of them.
+func (h *hub) subscribe(channels []string, queueMaxBytes int) (*subscriber, func()) {
+	id := atomic.AddUint64(&h.nextID, 1)
+	sub := newSubscriber(queueMaxBytes)
+
+	h.mu.Lock()
+	for _, channel := range channels {
+		if h.subs[channel] == nil {
+			h.subs[channel] = make(map[uint64]*subscriber)
+		}
+		h.subs[channel][id] = sub
+	}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		for _, channel := range channels {
+			delete(h.subs[channel], id)
+			if len(h.subs[channel]) == 0 {
+				delete(h.subs, channel)
+			}
+		}
+		h.mu.Unlock()
+	}
+	return sub, unsubscribe
+}
+
+// broadcast delivers pub to every local subscriber of channel.
+func (h *hub) broadcast(channel string, pub *proto.Publication) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, sub := range h.subs[channel] {
+		sub.push(channel, pub)
+	}
+}
@@ -0,0 +1,620 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/centrifugal/centrifugo/lib/channel"
+	"github.com/centrifugal/centrifugo/lib/engine"
+	"github.com/centrifugal/centrifugo/lib/engine/enginememory"
+	"github.com/centrifugal/centrifugo/lib/proto"
+	"github.com/centrifugal/centrifugo/lib/ratelimit"
+)
+
+// RPCEvent is passed to an RPC handler registered via Node.OnRPC.
+type RPCEvent struct {
+	// Method is the RPC method name the client called.
+	Method string
+	// Params is the raw, still-encoded call payload.
+	Params []byte
+}
+
+// RPCReply is returned by an RPC handler and sent back to the calling
+// client as the result of its `rpc` command.
+type RPCReply struct {
+	// Result is the raw payload returned to the client.
+	Result []byte
+	// Error, when non-nil, is sent to the client instead of Result.
+	Error error
+}
+
+// RPCHandler handles a single `rpc` command from a client and returns
+// the reply to send back.
+type RPCHandler func(ctx context.Context, client string, event RPCEvent) RPCReply
+
+// PublishEvent is passed to a handler registered via Node.OnPublish,
+// letting an application authorize client publications before they
+// reach the channel. The handler can not change the published data.
+type PublishEvent struct {
+	Channel string
+	Data    []byte
+}
+
+// PublishReply is returned by a PublishHandler. A non-nil Error stops
+// the publication - it is never delivered to the channel.
+type PublishReply struct {
+	Error error
+}
+
+// PublishHandler is called for every publication a client makes into a
+// channel, before it is routed to the engine.
+type PublishHandler func(ctx context.Context, client string, event PublishEvent) PublishReply
+
+// SubscribeEvent is passed to a handler registered via Node.OnSubscribe,
+// letting an application authorize a client's subscribe attempt.
+type SubscribeEvent struct {
+	Channel string
+}
+
+// SubscribeReply is returned by a SubscribeHandler. A non-nil Error
+// rejects the subscription.
+type SubscribeReply struct {
+	Error error
+}
+
+// SubscribeHandler is called for every channel subscribe attempt a
+// client makes that passed the token and limit checks. A rejection
+// undoes the subscription's count towards UserSubscriptionLimit.
+type SubscribeHandler func(ctx context.Context, client string, event SubscribeEvent) SubscribeReply
+
+// Node is the central object of a running Centrifugo server: it holds
+// configuration and the application-registered handlers that let
+// Centrifugo be used as a request/response RPC transport and not just
+// a broadcast bus.
+type Node struct {
+	config Config
+	hub    *hub
+
+	mu             sync.RWMutex
+	rpcHandlers    map[string]RPCHandler
+	publishHandler PublishHandler
+	subHandler     SubscribeHandler
+	controlHandler func(data []byte)
+
+	// clientUsers maps a connected client ID to the user ID from its
+	// connect token, populated by handleConnectCommand and cleaned up by
+	// Disconnect. clientSubs maps it to the set of channels it currently
+	// holds a subscription on, so Disconnect knows what to give back to
+	// userSubCounts - the per-user count enforcing UserSubscriptionLimit.
+	clientUsers   map[string]string
+	clientSubs    map[string]map[string]struct{}
+	userSubCounts map[string]int
+}
+
+// New creates a Node with the given Config. When c.Engine is nil it
+// defaults to an in-memory engine, suitable for running a single node;
+// set c.Engine to an engineredis.Engine to run a cluster of nodes
+// sharing state through Redis instead. When c.RateLimiter is nil it
+// defaults to an in-memory ratelimit.MemoryLimiter. Call Run once the
+// Node is fully configured (handlers registered) to start the engine
+// and have publications/control messages start reaching it.
+func New(c Config) *Node {
+	if c.Engine == nil {
+		c.Engine = enginememory.New()
+	}
+	if c.RateLimiter == nil {
+		c.RateLimiter = ratelimit.NewMemoryLimiter()
+	}
+	n := &Node{
+		config:        c,
+		hub:           newHub(),
+		rpcHandlers:   make(map[string]RPCHandler),
+		clientUsers:   make(map[string]string),
+		clientSubs:    make(map[string]map[string]struct{}),
+		userSubCounts: make(map[string]int),
+	}
+	n.config.Engine.SetEventHandler(n)
+	return n
+}
+
+// Run starts the configured Engine. No control message reaches
+// HandleControl before Run is called; publications reach
+// HandlePublication before Run only with the in-memory engine, which
+// delivers them from within Publish.
+func (n *Node) Run() error {
+	return n.config.Engine.Run()
+}
+
+// Shutdown stops the configured Engine.
+func (n *Node) Shutdown() error {
+	return n.config.Engine.Shutdown()
+}
+
+// Disconnect releases the bookkeeping kept for client - its authenticated
+// user (from connect) and its channel subscription counts towards
+// Config.UserSubscriptionLimit - so neither leaks once the underlying
+// connection is gone. A transport that terminates a client connection
+// must call this once, when the connection closes.
+func (n *Node) Disconnect(client string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	user := n.clientUsers[client]
+	for range n.clientSubs[client] {
+		n.decrementUserSubLocked(user)
+	}
+	delete(n.clientSubs, client)
+	delete(n.clientUsers, client)
+}
+
+// decrementUserSubLocked decrements user's subscription count, removing
+// the entry entirely once it reaches zero so userSubCounts doesn't grow
+// unbounded with zero-valued entries for users who disconnected.
+// n.mu must be held.
+func (n *Node) decrementUserSubLocked(user string) {
+	if user == "" {
+		return
+	}
+	n.userSubCounts[user]--
+	if n.userSubCounts[user] <= 0 {
+		delete(n.userSubCounts, user)
+	}
+}
+
+// HandlePublication implements engine.EventHandler: it fans a
+// publication the engine became aware of out to every local
+// subscriber of channel registered via Subscribe.
+func (n *Node) HandlePublication(channel string, pub *proto.Publication) {
+	n.hub.broadcast(channel, pub)
+}
+
+// OnControl registers the handler invoked for every inter-node control
+// message the engine delivers via PublishControl. Only one handler can
+// be registered at a time; with none registered, control messages are
+// received and simply dropped.
+func (n *Node) OnControl(handler func(data []byte)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.controlHandler = handler
+}
+
+// HandleControl implements engine.EventHandler.
+func (n *Node) HandleControl(data []byte) {
+	n.mu.RLock()
+	handler := n.controlHandler
+	n.mu.RUnlock()
+	if handler != nil {
+		handler(data)
+	}
+}
+
+// Subscription is a local subscriber registered via Node.Subscribe. It
+// must be closed once the caller is done with it.
+type Subscription struct {
+	sub         *subscriber
+	unsubscribe func()
+}
+
+// Ready is signalled whenever new publications are available to Drain.
+// It does not carry a value per-signal - a single send can mean several
+// publications were queued, so the caller should always Drain fully
+// after a wake-up rather than assuming one Ready equals one publication.
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.sub.ready
+}
+
+// Drain returns every publication queued since the last Drain call,
+// each paired with the channel it was published to. The returned bool
+// reports whether the subscriber has exceeded its queue's byte budget -
+// if true, the caller must close the connection after handling whatever
+// publications were returned.
+func (s *Subscription) Drain() ([]PushItem, bool) {
+	items, overflow := s.sub.drain()
+	result := make([]PushItem, len(items))
+	for i, it := range items {
+		result[i] = PushItem{Channel: it.channel, Publication: it.pub}
+	}
+	return result, overflow
+}
+
+// PushItem is a single publication delivered to a Subscription, paired
+// with the channel it was published to.
+type PushItem struct {
+	Channel     string
+	Publication *proto.Publication
+}
+
+// Close unregisters the subscription from the hub.
+func (s *Subscription) Close() {
+	s.unsubscribe()
+}
+
+// Subscribe registers a local subscriber for channels - used by the
+// transports that terminate a client connection on this node (the
+// unidirectional transports in package uni, WebSocket, SockJS) to
+// receive publications as they are made. All channels share a single
+// queue, bounded in bytes by queueMaxBytes; it should come from the
+// relevant per-transport queue size config option (e.g.
+// Config.UniSSEQueueMaxSize). The returned Subscription must be closed
+// once the connection is done with it.
+func (n *Node) Subscribe(channels []string, queueMaxBytes int) *Subscription {
+	sub, unsubscribe := n.hub.subscribe(channels, queueMaxBytes)
+	return &Subscription{sub: sub, unsubscribe: unsubscribe}
+}
+
+// OnRPC registers a handler for the given RPC method name. Calling it
+// again for the same method replaces the previous handler.
+func (n *Node) OnRPC(method string, handler RPCHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rpcHandlers[method] = handler
+}
+
+// OnPublish registers the handler invoked for every client publish,
+// used to authorize publications before they are routed to the
+// channel. Only one handler can be registered at a time.
+func (n *Node) OnPublish(handler PublishHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.publishHandler = handler
+}
+
+// OnSubscribe registers the handler invoked for every client
+// subscribe attempt, used to authorize the subscription. Only one
+// handler can be registered at a time.
+func (n *Node) OnSubscribe(handler SubscribeHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subHandler = handler
+}
+
+// ErrMethodNotFound is returned by dispatchRPC when no handler was
+// registered via OnRPC for the requested method.
+var ErrMethodNotFound = rpcError("node: method not found")
+
+type rpcError string
+
+func (e rpcError) Error() string { return string(e) }
+
+// dispatchRPC routes an `rpc` command from client to the handler
+// registered for event.Method, if any.
+func (n *Node) dispatchRPC(ctx context.Context, client string, event RPCEvent) RPCReply {
+	n.mu.RLock()
+	handler, ok := n.rpcHandlers[event.Method]
+	n.mu.RUnlock()
+	if !ok {
+		return RPCReply{Error: ErrMethodNotFound}
+	}
+	return handler(ctx, client, event)
+}
+
+// dispatchPublish runs the registered PublishHandler, if any, allowing
+// every publication through when none is registered.
+func (n *Node) dispatchPublish(ctx context.Context, client string, event PublishEvent) PublishReply {
+	n.mu.RLock()
+	handler := n.publishHandler
+	n.mu.RUnlock()
+	if handler == nil {
+		return PublishReply{}
+	}
+	return handler(ctx, client, event)
+}
+
+// dispatchSubscribe runs the registered SubscribeHandler, if any,
+// allowing every subscription through when none is registered.
+func (n *Node) dispatchSubscribe(ctx context.Context, client string, event SubscribeEvent) SubscribeReply {
+	n.mu.RLock()
+	handler := n.subHandler
+	n.mu.RUnlock()
+	if handler == nil {
+		return SubscribeReply{}
+	}
+	return handler(ctx, client, event)
+}
+
+// HandleCommand is the client protocol command dispatcher: a
+// bidirectional transport terminating a client connection (WebSocket,
+// SockJS) decodes a single proto.Command off the wire and calls this to
+// get the proto.Reply to send back. The unidirectional transports in
+// package uni read no commands and do not call it. It is what actually
+// wires `rpc`/`publish`/`subscribe` commands through to the handlers
+// registered via OnRPC/OnPublish/OnSubscribe - those handlers have no
+// effect until something calls HandleCommand for an incoming command.
+func (n *Node) HandleCommand(ctx context.Context, client string, cmd *proto.Command) *proto.Reply {
+	if cmd.Method != "connect" {
+		n.mu.RLock()
+		_, connected := n.clientUsers[client]
+		n.mu.RUnlock()
+		if !connected {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError("connect command required first")}
+		}
+	}
+	switch cmd.Method {
+	case "connect":
+		return n.handleConnectCommand(ctx, client, cmd)
+	case "rpc":
+		return n.handleRPCCommand(ctx, client, cmd)
+	case "publish":
+		return n.handlePublishCommand(ctx, client, cmd)
+	case "subscribe":
+		return n.handleSubscribeCommand(ctx, client, cmd)
+	default:
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{
+			Code:    proto.ErrCodeMethodNotFound,
+			Message: "unknown command method: " + cmd.Method,
+		}}
+	}
+}
+
+// unauthorizedError builds the Error sent to a client whose connect or
+// subscribe token failed verification.
+func unauthorizedError(message string) *proto.Error {
+	return &proto.Error{Code: proto.ErrCodeUnauthorized, Message: message}
+}
+
+// handleConnectCommand is the first command a client must send: it
+// authenticates the connection via Config.TokenVerifier before any
+// other command is accepted. With Config.ClientInsecure on, a token is
+// not required and the connection is treated as anonymous.
+func (n *Node) handleConnectCommand(ctx context.Context, client string, cmd *proto.Command) *proto.Reply {
+	var req proto.ConnectRequest
+	if len(cmd.Params) > 0 {
+		if err := json.Unmarshal(cmd.Params, &req); err != nil {
+			return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "malformed connect params"}}
+		}
+	}
+
+	var user string
+	if !n.config.ClientInsecure {
+		if req.Token == "" {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError("connect token required")}
+		}
+		if n.config.TokenVerifier == nil {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError("no token verifier configured")}
+		}
+		claims, err := n.config.TokenVerifier.VerifyConnectToken(req.Token)
+		if err != nil {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError(err.Error())}
+		}
+		user = claims.Subject
+	}
+
+	n.mu.Lock()
+	n.clientUsers[client] = user
+	n.mu.Unlock()
+
+	result, err := json.Marshal(proto.ConnectResult{Client: client, User: user})
+	if err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+	}
+	return &proto.Reply{ID: cmd.ID, Result: result}
+}
+
+func (n *Node) handleRPCCommand(ctx context.Context, client string, cmd *proto.Command) *proto.Reply {
+	var req proto.RPCRequest
+	if err := json.Unmarshal(cmd.Params, &req); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "malformed rpc params"}}
+	}
+
+	reply := n.dispatchRPC(ctx, client, RPCEvent{Method: req.Method, Params: req.Data})
+	if reply.Error != nil {
+		return &proto.Reply{ID: cmd.ID, Error: rpcReplyError(reply.Error)}
+	}
+
+	result, err := json.Marshal(proto.RPCResult{Data: reply.Result})
+	if err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+	}
+	return &proto.Reply{ID: cmd.ID, Result: result}
+}
+
+func (n *Node) handlePublishCommand(ctx context.Context, client string, cmd *proto.Command) *proto.Reply {
+	var req proto.PublishRequest
+	if err := json.Unmarshal(cmd.Params, &req); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "malformed publish params"}}
+	}
+
+	namespace := n.config.channelNamespace(req.Channel)
+	opts, ok := n.config.channelOpts(namespace)
+	if !ok {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "unknown channel namespace"}}
+	}
+	if !opts.Publish && !n.config.ClientInsecure {
+		return &proto.Reply{ID: cmd.ID, Error: unauthorizedError("client publish not allowed in this channel")}
+	}
+
+	limitKey := namespace + ":" + client + ":publish"
+	if err := n.config.RateLimiter.Allow(limitKey, opts.PublishRateLimit, opts.PublishBurst); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: proto.NewLimitExceededError()}
+	}
+
+	reply := n.dispatchPublish(ctx, client, PublishEvent{Channel: req.Channel, Data: req.Data})
+	if reply.Error != nil {
+		return &proto.Reply{ID: cmd.ID, Error: rpcReplyError(reply.Error)}
+	}
+
+	pub := &proto.Publication{Data: req.Data}
+	publishOpts := &engine.PublishOptions{
+		HistorySize:     opts.HistorySize,
+		HistoryLifetime: opts.HistoryLifetime,
+		UseBinary:       opts.UseBinary,
+	}
+	if err := n.config.Engine.Publish(req.Channel, pub, publishOpts); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+	}
+	// Delivery to local subscribers (including this node's own, for a
+	// single-node deployment) happens via HandlePublication, called by
+	// the engine once it observes this publish - not here - so the same
+	// path is used whether the publish reached us via a real client or
+	// relayed from another node over engineredis.
+	return &proto.Reply{ID: cmd.ID}
+}
+
+func (n *Node) handleSubscribeCommand(ctx context.Context, client string, cmd *proto.Command) *proto.Reply {
+	var req proto.SubscribeRequest
+	if err := json.Unmarshal(cmd.Params, &req); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "malformed subscribe params"}}
+	}
+
+	if n.config.isPrivateChannel(req.Channel) && !n.config.ClientInsecure {
+		if err := n.verifySubscribeToken(client, req.Channel, req.Token); err != nil {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError(err.Error())}
+		}
+	}
+
+	namespace := n.config.channelNamespace(req.Channel)
+	opts, ok := n.config.channelOpts(namespace)
+	if !ok {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: "unknown channel namespace"}}
+	}
+	if !opts.Anonymous && !n.config.ClientInsecure {
+		n.mu.RLock()
+		user := n.clientUsers[client]
+		n.mu.RUnlock()
+		if user == "" {
+			return &proto.Reply{ID: cmd.ID, Error: unauthorizedError("anonymous access not allowed in this channel")}
+		}
+	}
+
+	limitKey := namespace + ":" + client + ":subscribe"
+	if err := n.config.RateLimiter.Allow(limitKey, opts.SubscribeRateLimit, opts.SubscribeRateLimit); err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: proto.NewLimitExceededError()}
+	}
+
+	registered, err := n.registerSubscription(client, req.Channel)
+	if err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: proto.NewLimitExceededError()}
+	}
+
+	reply := n.dispatchSubscribe(ctx, client, SubscribeEvent{Channel: req.Channel})
+	if reply.Error != nil {
+		if registered {
+			n.unregisterSubscription(client, req.Channel)
+		}
+		return &proto.Reply{ID: cmd.ID, Error: rpcReplyError(reply.Error)}
+	}
+
+	var subResult proto.SubscribeResult
+	if req.Recover && opts.HistoryRecover {
+		subResult, err = n.recoverHistory(req, opts)
+		if err != nil {
+			return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+		}
+	} else if opts.HistoryRecover {
+		hr, err := n.config.Engine.History(req.Channel, engine.HistoryFilter{Limit: 1, UseBinary: opts.UseBinary})
+		if err != nil {
+			return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+		}
+		subResult.Offset = hr.Position.Offset
+		subResult.Epoch = hr.Position.Epoch
+	}
+
+	result, err := json.Marshal(subResult)
+	if err != nil {
+		return &proto.Reply{ID: cmd.ID, Error: &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}}
+	}
+	return &proto.Reply{ID: cmd.ID, Result: result}
+}
+
+// registerSubscription records that client now holds a subscription on
+// channel, rejecting it with ErrLimitExceeded when that would put the
+// connecting user (from its connect token) over
+// Config.UserSubscriptionLimit. Resubscribing to a channel the client
+// is already subscribed to is idempotent and never counted twice.
+// Returns whether a new registration was actually added, so the caller
+// can undo it via unregisterSubscription if a later step rejects the
+// subscription.
+func (n *Node) registerSubscription(client string, channel string) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.clientSubs[client] != nil {
+		if _, ok := n.clientSubs[client][channel]; ok {
+			return false, nil
+		}
+	}
+
+	user := n.clientUsers[client]
+	if user != "" && n.config.UserSubscriptionLimit > 0 && n.userSubCounts[user] >= n.config.UserSubscriptionLimit {
+		return false, ratelimit.ErrLimitExceeded
+	}
+
+	if n.clientSubs[client] == nil {
+		n.clientSubs[client] = make(map[string]struct{})
+	}
+	n.clientSubs[client][channel] = struct{}{}
+	if user != "" {
+		n.userSubCounts[user]++
+	}
+	return true, nil
+}
+
+// unregisterSubscription undoes a registerSubscription call, used when a
+// subscription is rejected by a later check after having been counted.
+func (n *Node) unregisterSubscription(client string, channel string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.clientSubs[client][channel]; !ok {
+		return
+	}
+	delete(n.clientSubs[client], channel)
+	if len(n.clientSubs[client]) == 0 {
+		delete(n.clientSubs, client)
+	}
+	n.decrementUserSubLocked(n.clientUsers[client])
+}
+
+// recoverHistory replays publications missed since (req.Offset,
+// req.Epoch) from channel history, as requested by a resubscribing
+// client that set SubscribeRequest.Recover. Recovered is only true when
+// the epoch still matches and nothing the client missed was already
+// evicted from history (engine.ErrTombstone) - in every other case the
+// client must treat its local state as stale and resync from scratch;
+// Offset/Epoch are still reported so it can store the new position for
+// its next recovery attempt.
+func (n *Node) recoverHistory(req proto.SubscribeRequest, opts channel.Options) (proto.SubscribeResult, error) {
+	hr, err := n.config.Engine.History(req.Channel, engine.HistoryFilter{
+		Since:     &engine.StreamPosition{Offset: req.Offset, Epoch: req.Epoch},
+		UseBinary: opts.UseBinary,
+	})
+	if err != nil && err != engine.ErrTombstone {
+		return proto.SubscribeResult{}, err
+	}
+	return proto.SubscribeResult{
+		Publications: hr.Publications,
+		Recovered:    err == nil && hr.Position.Epoch == req.Epoch,
+		Offset:       hr.Position.Offset,
+		Epoch:        hr.Position.Epoch,
+	}, nil
+}
+
+// verifySubscribeToken checks that token is a valid subscribe token
+// issued for exactly this client and channel, so a token can't be
+// replayed to subscribe a different connection or a different private
+// channel than the one it was signed for.
+func (n *Node) verifySubscribeToken(client string, channel string, token string) error {
+	if token == "" {
+		return errors.New("node: subscribe token required for private channel")
+	}
+	if n.config.TokenVerifier == nil {
+		return errors.New("node: no token verifier configured")
+	}
+	claims, err := n.config.TokenVerifier.VerifySubscribeToken(token)
+	if err != nil {
+		return err
+	}
+	if claims.Client != client || claims.Channel != channel {
+		return errors.New("node: subscribe token does not match client or channel")
+	}
+	return nil
+}
+
+// rpcReplyError converts a handler-returned error into a protocol Error
+// sent to the client.
+func rpcReplyError(err error) *proto.Error {
+	if err == ErrMethodNotFound {
+		return &proto.Error{Code: proto.ErrCodeMethodNotFound, Message: err.Error()}
+	}
+	return &proto.Error{Code: proto.ErrCodeInternal, Message: err.Error()}
+}
@@ -0,0 +1,21 @@
+package proto
+
+// ConnectRequest is the decoded params of a `connect` Command - the
+// first command a client must send, establishing its identity before
+// any `rpc`/`publish`/`subscribe` command is accepted.
+type ConnectRequest struct {
+	// Token is a JWT connection token, verified against
+	// Config.TokenVerifier. Required unless Config.ClientInsecure is on.
+	Token string `json:"token,omitempty"`
+}
+
+// ConnectResult is the encoded Reply.Result for a `connect` Command.
+type ConnectResult struct {
+	// Client is the server-assigned ID of this connection, the same
+	// value passed as `client` to Node.HandleCommand for every
+	// subsequent command on it.
+	Client string `json:"client"`
+	// User is the authenticated user ID from the connect token's
+	// Subject claim, empty for anonymous connections.
+	User string `json:"user,omitempty"`
+}
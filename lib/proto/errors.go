@@ -0,0 +1,16 @@
+package proto
+
+// Protocol-level error codes sent to clients in Reply.Error.Code.
+const (
+	ErrCodeInternal       = 100
+	ErrCodeUnauthorized   = 101
+	ErrCodeMethodNotFound = 102
+	ErrCodeLimitExceeded  = 103
+)
+
+// NewLimitExceededError builds the Error sent to a client that hit a
+// publish or subscribe rate limit, so it can back off instead of
+// retrying immediately.
+func NewLimitExceededError() *Error {
+	return &Error{Code: ErrCodeLimitExceeded, Message: "limit exceeded"}
+}
@@ -0,0 +1,27 @@
+// Package proto contains the data types exchanged between client,
+// engine and API subsystems - the wire format of the client protocol.
+package proto
+
+import "encoding/json"
+
+// ClientInfo contains information about a client connection, used in
+// presence and join/leave messages. ConnInfo/ChanInfo are kept as raw
+// bytes (not interface{}) so they round-trip identically whether the
+// surrounding message is JSON- or Protobuf-encoded - json.RawMessage
+// marshals to JSON verbatim, and the exact same bytes are what the
+// `bytes conn_info`/`bytes chan_info` fields in client.proto carry over
+// the wire for the Protobuf codec.
+type ClientInfo struct {
+	User     string          `json:"user"`
+	Client   string          `json:"client"`
+	ConnInfo json.RawMessage `json:"conn_info,omitempty"`
+	ChanInfo json.RawMessage `json:"chan_info,omitempty"`
+}
+
+// Publication is a single message published to a channel. Data is kept
+// as raw bytes for the same reason as ClientInfo.ConnInfo above.
+type Publication struct {
+	UID  string          `json:"uid,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Info *ClientInfo     `json:"info,omitempty"`
+}
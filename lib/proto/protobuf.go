@@ -0,0 +1,229 @@
+package proto
+
+// This file implements the hand-written equivalent of what `protoc
+// --go_out` would generate from client.proto. Field numbers and wire
+// types below must stay in sync with that schema.
+
+import (
+	"encoding/binary"
+)
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(buf []byte, fieldNum int, wireType int) []byte {
+	return appendVarint(buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendBytesField(buf []byte, fieldNum int, data []byte) []byte {
+	if len(data) == 0 {
+		return buf
+	}
+	buf = appendTag(buf, fieldNum, wireBytes)
+	buf = appendVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendStringField(buf []byte, fieldNum int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	return appendBytesField(buf, fieldNum, []byte(s))
+}
+
+func appendVarintField(buf []byte, fieldNum int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendTag(buf, fieldNum, wireVarint)
+	return appendVarint(buf, v)
+}
+
+func marshalCommand(c *Command) ([]byte, error) {
+	var buf []byte
+	buf = appendVarintField(buf, 1, uint64(c.ID))
+	buf = appendStringField(buf, 2, c.Method)
+	buf = appendBytesField(buf, 3, c.Params)
+	return buf, nil
+}
+
+func marshalReply(r *Reply) ([]byte, error) {
+	var buf []byte
+	buf = appendVarintField(buf, 1, uint64(r.ID))
+	if r.Error != nil {
+		var errBuf []byte
+		errBuf = appendVarintField(errBuf, 1, uint64(r.Error.Code))
+		errBuf = appendStringField(errBuf, 2, r.Error.Message)
+		buf = appendBytesField(buf, 2, errBuf)
+	}
+	buf = appendBytesField(buf, 3, r.Result)
+	return buf, nil
+}
+
+func marshalPush(p *Push) ([]byte, error) {
+	var buf []byte
+	buf = appendStringField(buf, 1, p.Type)
+	buf = appendStringField(buf, 2, p.Channel)
+	buf = appendBytesField(buf, 3, p.Data)
+	return buf, nil
+}
+
+// parseFields walks data's tag/length/value fields, calling onVarint or
+// onBytes for each one depending on its wire type. Every unmarshal
+// function in this file is a thin wrapper around it, so the field
+// numbers below are the only thing that needs to stay in sync with
+// client.proto.
+func parseFields(data []byte, onVarint func(num int, v uint64), onBytes func(num int, val []byte)) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errShortBuffer
+		}
+		data = data[n:]
+		fieldNum := int(tag >> 3)
+		wireType := int(tag & 0x7)
+
+		switch wireType {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errShortBuffer
+			}
+			data = data[n:]
+			onVarint(fieldNum, v)
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errShortBuffer
+			}
+			data = data[n:]
+			if uint64(len(data)) < l {
+				return errShortBuffer
+			}
+			val := data[:l]
+			data = data[l:]
+			onBytes(fieldNum, val)
+		default:
+			return errShortBuffer
+		}
+	}
+	return nil
+}
+
+// unmarshalCommand parses the minimal tag/length/value fields used by
+// Command. It's intentionally forgiving of unknown fields so it stays
+// forward-compatible with newer client.proto revisions.
+func unmarshalCommand(data []byte) (*Command, error) {
+	c := &Command{}
+	err := parseFields(data,
+		func(num int, v uint64) {
+			if num == 1 {
+				c.ID = uint32(v)
+			}
+		},
+		func(num int, val []byte) {
+			switch num {
+			case 2:
+				c.Method = string(val)
+			case 3:
+				c.Params = val
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// marshalClientInfo encodes a ClientInfo per client.proto's ClientInfo
+// message (user=1 string, client=2 string, conn_info=3 bytes,
+// chan_info=4 bytes).
+func marshalClientInfo(info *ClientInfo) []byte {
+	var buf []byte
+	buf = appendStringField(buf, 1, info.User)
+	buf = appendStringField(buf, 2, info.Client)
+	buf = appendBytesField(buf, 3, info.ConnInfo)
+	buf = appendBytesField(buf, 4, info.ChanInfo)
+	return buf
+}
+
+func unmarshalClientInfo(data []byte) (*ClientInfo, error) {
+	info := &ClientInfo{}
+	err := parseFields(data,
+		func(num int, v uint64) {},
+		func(num int, val []byte) {
+			switch num {
+			case 1:
+				info.User = string(val)
+			case 2:
+				info.Client = string(val)
+			case 3:
+				info.ConnInfo = append([]byte(nil), val...)
+			case 4:
+				info.ChanInfo = append([]byte(nil), val...)
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// MarshalPublication encodes a Publication per client.proto's
+// Publication message (uid=1 string, data=2 bytes, info=3 embedded
+// ClientInfo message) - this is the Protobuf codec
+// channel.Options.UseBinary selects for history/presence storage.
+func MarshalPublication(pub *Publication) ([]byte, error) {
+	var buf []byte
+	buf = appendStringField(buf, 1, pub.UID)
+	buf = appendBytesField(buf, 2, pub.Data)
+	if pub.Info != nil {
+		buf = appendBytesField(buf, 3, marshalClientInfo(pub.Info))
+	}
+	return buf, nil
+}
+
+// UnmarshalPublication decodes bytes produced by MarshalPublication.
+func UnmarshalPublication(data []byte) (*Publication, error) {
+	pub := &Publication{}
+	err := parseFields(data,
+		func(num int, v uint64) {},
+		func(num int, val []byte) {
+			switch num {
+			case 1:
+				pub.UID = string(val)
+			case 2:
+				pub.Data = append([]byte(nil), val...)
+			case 3:
+				if info, err := unmarshalClientInfo(val); err == nil {
+					pub.Info = info
+				}
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// MarshalClientInfo encodes a ClientInfo using the Protobuf codec - the
+// counterpart to MarshalPublication for presence storage.
+func MarshalClientInfo(info *ClientInfo) ([]byte, error) {
+	return marshalClientInfo(info), nil
+}
+
+// UnmarshalClientInfo decodes bytes produced by MarshalClientInfo.
+func UnmarshalClientInfo(data []byte) (*ClientInfo, error) {
+	return unmarshalClientInfo(data)
+}
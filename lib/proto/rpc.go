@@ -0,0 +1,21 @@
+package proto
+
+// RPCRequest is the decoded params of an `rpc` Command: it carries the
+// application-defined method name on top of the protocol-level
+// Command, so a single `rpc` command type can route to any number of
+// handlers registered via Node.OnRPC.
+type RPCRequest struct {
+	Method string `json:"method"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// RPCResult is the encoded Reply.Result for an `rpc` Command.
+type RPCResult struct {
+	Data []byte `json:"data,omitempty"`
+}
+
+// PublishRequest is the decoded params of a `publish` Command.
+type PublishRequest struct {
+	Channel string `json:"channel"`
+	Data    []byte `json:"data"`
+}
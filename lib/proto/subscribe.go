@@ -0,0 +1,34 @@
+package proto
+
+// SubscribeRequest is the decoded params of a `subscribe` Command. When
+// Recover is set, the server attempts to replay publications missed
+// since (Offset, Epoch) from channel history instead of just
+// confirming the subscription.
+type SubscribeRequest struct {
+	Channel string `json:"channel"`
+	Token   string `json:"token,omitempty"`
+
+	Recover bool   `json:"recover,omitempty"`
+	Offset  uint64 `json:"offset,omitempty"`
+	Epoch   string `json:"epoch,omitempty"`
+}
+
+// SubscribeResult is the encoded Reply.Result for a `subscribe` Command.
+type SubscribeResult struct {
+	Publications []*Publication `json:"publications,omitempty"`
+
+	// Recovered is true when Recover was requested, the epoch matched
+	// and every publication since the requested offset was still in
+	// history, so Publications above is the complete set the client
+	// missed. When false and Recover was requested, the client should
+	// treat its local state as stale and resync from the source of
+	// truth rather than trust Publications.
+	Recovered bool `json:"recovered"`
+
+	// Offset and Epoch describe the current stream position, so a
+	// client can store them to recover from this point after its next
+	// disconnect. They are left empty for channels without
+	// HistoryRecover.
+	Offset uint64 `json:"offset,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
+}
@@ -0,0 +1,108 @@
+// Package ratelimit implements token-bucket rate limiting for the
+// publish and subscribe paths, enforced per client connection and
+// namespace.
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLimitExceeded is returned by Limiter.Allow when the caller has no
+// tokens left. Callers surface it to the client as a distinct
+// `LimitExceeded` protocol error so clients can back off instead of
+// retrying immediately.
+var ErrLimitExceeded = errors.New("ratelimit: limit exceeded")
+
+// Limiter enforces a token-bucket rate limit for a single key (for
+// example "<namespace>:<client>:publish").
+type Limiter interface {
+	// Allow reports whether an action identified by key is allowed to
+	// proceed right now, given a steady rate of ratePerSecond tokens and
+	// a bucket size of burst. It returns ErrLimitExceeded when the
+	// bucket has no tokens left.
+	Allow(key string, ratePerSecond int, burst int) error
+}
+
+// bucketEvictAfter is how long a bucket can sit unused before it's
+// swept by MemoryLimiter.Allow - long enough that a steady client never
+// loses its accumulated burst allowance between calls, short enough
+// that a connection/namespace keyspace that churns through many client
+// IDs doesn't grow the buckets map forever.
+const bucketEvictAfter = 10 * time.Minute
+
+// bucketSweepInterval is how often MemoryLimiter.Allow piggy-backs an
+// eviction sweep onto a regular call, instead of running a dedicated
+// goroutine just to prune idle buckets.
+const bucketSweepInterval = time.Minute
+
+// MemoryLimiter is an in-memory Limiter suitable for a single node
+// deployment. Each key gets its own token bucket, lazily created on
+// first use and evicted once it has been idle for bucketEvictAfter.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates a MemoryLimiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+type bucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// Allow implements Limiter.
+func (l *MemoryLimiter) Allow(key string, ratePerSecond int, burst int) error {
+	if ratePerSecond <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweepLocked(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), lastRefill: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+
+	b.tokens += elapsed * float64(ratePerSecond)
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+
+	if b.tokens < 1 {
+		return ErrLimitExceeded
+	}
+	b.tokens--
+	return nil
+}
+
+// sweepLocked removes buckets idle for longer than bucketEvictAfter. It
+// must be called with l.mu held, and only actually walks the map once
+// per bucketSweepInterval.
+func (l *MemoryLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastRefill) > bucketEvictAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
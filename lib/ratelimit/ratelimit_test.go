@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+)
+
+var errRedisUnavailable = errors.New("ratelimit_test: redis unavailable")
+
+func TestMemoryLimiterAllowsWithinBurst(t *testing.T) {
+	l := NewMemoryLimiter()
+	for i := 0; i < 3; i++ {
+		if err := l.Allow("key", 1, 3); err != nil {
+			t.Fatalf("call %d: expected no error within burst, got %v", i, err)
+		}
+	}
+}
+
+func TestMemoryLimiterRejectsOverBurst(t *testing.T) {
+	l := NewMemoryLimiter()
+	for i := 0; i < 3; i++ {
+		if err := l.Allow("key", 1, 3); err != nil {
+			t.Fatalf("call %d: expected no error within burst, got %v", i, err)
+		}
+	}
+	if err := l.Allow("key", 1, 3); err != ErrLimitExceeded {
+		t.Fatalf("expected ErrLimitExceeded once burst is exhausted, got %v", err)
+	}
+}
+
+func TestMemoryLimiterDisabledWhenRateZero(t *testing.T) {
+	l := NewMemoryLimiter()
+	for i := 0; i < 100; i++ {
+		if err := l.Allow("key", 0, 0); err != nil {
+			t.Fatalf("expected no limiting when ratePerSecond is 0, got %v", err)
+		}
+	}
+}
+
+func TestMemoryLimiterSeparateKeysIndependent(t *testing.T) {
+	l := NewMemoryLimiter()
+	if err := l.Allow("a", 1, 1); err != nil {
+		t.Fatalf("expected key a to be allowed, got %v", err)
+	}
+	if err := l.Allow("a", 1, 1); err != ErrLimitExceeded {
+		t.Fatalf("expected key a to be exhausted, got %v", err)
+	}
+	if err := l.Allow("b", 1, 1); err != nil {
+		t.Fatalf("expected independent key b to be allowed, got %v", err)
+	}
+}
+
+func TestRedisLimiterAllowsUnderLimit(t *testing.T) {
+	l := NewRedisLimiter(func(key string, window int) (int64, error) {
+		return 2, nil
+	})
+	if err := l.Allow("key", 5, 5); err != nil {
+		t.Fatalf("expected no error under limit, got %v", err)
+	}
+}
+
+func TestRedisLimiterRejectsOverLimit(t *testing.T) {
+	l := NewRedisLimiter(func(key string, window int) (int64, error) {
+		return 11, nil
+	})
+	if err := l.Allow("key", 5, 5); err != ErrLimitExceeded {
+		t.Fatalf("expected ErrLimitExceeded over limit, got %v", err)
+	}
+}
+
+func TestRedisLimiterDisabledWhenRateZero(t *testing.T) {
+	l := NewRedisLimiter(func(key string, window int) (int64, error) {
+		t.Fatal("incrExpire should not be called when ratePerSecond is 0")
+		return 0, nil
+	})
+	if err := l.Allow("key", 0, 0); err != nil {
+		t.Fatalf("expected no limiting when ratePerSecond is 0, got %v", err)
+	}
+}
+
+func TestRedisLimiterFailsOpenOnError(t *testing.T) {
+	l := NewRedisLimiter(func(key string, window int) (int64, error) {
+		return 0, errRedisUnavailable
+	})
+	if err := l.Allow("key", 5, 5); err != nil {
+		t.Fatalf("expected fail-open behaviour on incrExpire error, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package uni
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/centrifugal/centrifugo/lib/node"
+	"github.com/centrifugal/centrifugo/lib/proto"
+)
+
+// PushWriter is implemented by each concrete unidirectional transport
+// and knows how to frame and flush a single outgoing message to the
+// underlying connection.
+type PushWriter interface {
+	// WritePush writes a single encoded push frame to the client.
+	WritePush(data []byte) error
+	// Close closes the underlying transport connection.
+	Close() error
+}
+
+// Config holds the unidirectional transport settings pulled out of
+// node.Config, passed down to each transport handler.
+type Config struct {
+	// Node is used to subscribe the connection to its bootstrapped
+	// channels and receive publications pushed to them.
+	Node *node.Node
+	// MaxConnectionAge, when non-zero, makes the server proactively
+	// close a unidirectional connection once it has been open this
+	// long, forcing the client to reconnect and re-resolve routing
+	// (useful behind load balancers that rebalance on new connections).
+	MaxConnectionAge time.Duration
+	// SSEQueueMaxSize is the maximum size in bytes of a single client's
+	// pending message queue for the SSE transport.
+	SSEQueueMaxSize int
+	// StreamQueueMaxSize is the maximum size in bytes of a single
+	// client's pending message queue for chunked HTTP-streaming.
+	StreamQueueMaxSize int
+	// WebsocketQueueMaxSize is the maximum size in bytes of a single
+	// client's pending message queue for the unidirectional WebSocket
+	// transport.
+	WebsocketQueueMaxSize int
+}
+
+// maxConnectionAgeChan returns a channel that fires once cfg.MaxConnectionAge
+// has elapsed, or nil (and never fires) when no max age is configured -
+// exactly what a nil case in a select is for.
+func maxConnectionAgeChan(maxAge time.Duration) <-chan time.Time {
+	if maxAge <= 0 {
+		return nil
+	}
+	return time.After(maxAge)
+}
+
+// encodePush JSON-encodes a single pushed publication as a proto.Push
+// frame - the unidirectional transports don't negotiate a wire format
+// the way bidirectional WebSocket/SockJS connections do, so they always
+// use JSON.
+func encodePush(item node.PushItem) ([]byte, error) {
+	return proto.GetEncoder(proto.FormatJSON).EncodePush(&proto.Push{
+		Type:    "publication",
+		Channel: item.Channel,
+		Data:    item.Publication.Data,
+	})
+}
+
+// SSEHandler serves Server-Sent Events (`text/event-stream`) pushes.
+type SSEHandler struct {
+	config Config
+}
+
+// NewSSEHandler creates an SSEHandler.
+func NewSSEHandler(config Config) *SSEHandler {
+	return &SSEHandler{config: config}
+}
+
+// ServeHTTP writes the SSE response headers, subscribes to the
+// bootstrapped channels and keeps the connection open, pushing each
+// publication as an SSE event, until the client disconnects, the
+// subscriber's queue overflows or MaxConnectionAge elapses.
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	boot := ParseBootstrapRequest(r)
+	if len(boot.Channels) == 0 {
+		http.Error(w, "no channels requested", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sub := h.config.Node.Subscribe(boot.Channels, h.config.SSEQueueMaxSize)
+	defer sub.Close()
+
+	maxAge := maxConnectionAgeChan(h.config.MaxConnectionAge)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-maxAge:
+			return
+		case <-sub.Ready():
+			items, overflow := sub.Drain()
+			for _, item := range items {
+				data, err := encodePush(item)
+				if err != nil {
+					continue
+				}
+				// No `id:` field is sent - these transports don't support
+				// resuming from a missed position (see BootstrapRequest),
+				// so there is nothing a reconnect could do with one.
+				if _, err := w.Write([]byte("data: ")); err != nil {
+					return
+				}
+				if _, err := w.Write(data); err != nil {
+					return
+				}
+				if _, err := w.Write([]byte("\n\n")); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if overflow {
+				return
+			}
+		}
+	}
+}
+
+// StreamHandler serves long-polling pushes as a chunked HTTP response
+// body, one JSON frame per line.
+type StreamHandler struct {
+	config Config
+}
+
+// NewStreamHandler creates a StreamHandler.
+func NewStreamHandler(config Config) *StreamHandler {
+	return &StreamHandler{config: config}
+}
+
+// ServeHTTP writes a chunked `application/json` stream response,
+// subscribes to the bootstrapped channels and keeps the connection open,
+// writing each publication as its own JSON line, until the client
+// disconnects, the subscriber's queue overflows or MaxConnectionAge
+// elapses.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	boot := ParseBootstrapRequest(r)
+	if len(boot.Channels) == 0 {
+		http.Error(w, "no channels requested", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "application/json")
+	header.Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sub := h.config.Node.Subscribe(boot.Channels, h.config.StreamQueueMaxSize)
+	defer sub.Close()
+
+	maxAge := maxConnectionAgeChan(h.config.MaxConnectionAge)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-maxAge:
+			return
+		case <-sub.Ready():
+			items, overflow := sub.Drain()
+			for _, item := range items {
+				data, err := encodePush(item)
+				if err != nil {
+					continue
+				}
+				if _, err := w.Write(append(data, '\n')); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if overflow {
+				return
+			}
+		}
+	}
+}
+
+// WebsocketHandler upgrades the connection to a WebSocket where the
+// server only ever writes frames - the client is not expected to send
+// any protocol commands.
+type WebsocketHandler struct {
+	config   Config
+	upgrader websocket.Upgrader
+}
+
+// NewWebsocketHandler creates a WebsocketHandler.
+func NewWebsocketHandler(config Config) *WebsocketHandler {
+	return &WebsocketHandler{config: config}
+}
+
+// ServeHTTP upgrades the connection, subscribes to the bootstrapped
+// channels and writes each publication as its own WebSocket text
+// message, until the client disconnects, the subscriber's queue
+// overflows or MaxConnectionAge elapses.
+func (h *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	boot := ParseBootstrapRequest(r)
+	if len(boot.Channels) == 0 {
+		http.Error(w, "no channels requested", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub := h.config.Node.Subscribe(boot.Channels, h.config.WebsocketQueueMaxSize)
+	defer sub.Close()
+
+	// The client sends no commands, but reading is what surfaces its
+	// close frame or a dropped connection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	maxAge := maxConnectionAgeChan(h.config.MaxConnectionAge)
+	for {
+		select {
+		case <-closed:
+			return
+		case <-maxAge:
+			return
+		case <-sub.Ready():
+			items, overflow := sub.Drain()
+			for _, item := range items {
+				data, err := encodePush(item)
+				if err != nil {
+					continue
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					return
+				}
+			}
+			if overflow {
+				return
+			}
+		}
+	}
+}
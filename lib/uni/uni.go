@@ -0,0 +1,52 @@
+// Package uni implements unidirectional server-push transports: SSE,
+// chunked HTTP-streaming and a unidirectional WebSocket variant where
+// the server only pushes data and never reads client commands.
+package uni
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TransportType identifies one of the unidirectional transports.
+type TransportType string
+
+// Supported unidirectional transport types.
+const (
+	TransportSSE          TransportType = "sse"
+	TransportHTTPStream   TransportType = "http_stream"
+	TransportUniWebsocket TransportType = "uni_websocket"
+	channelsQueryParam                  = "channels"
+)
+
+// BootstrapRequest describes the channels a unidirectional client wants
+// to subscribe to. Unlike the bidirectional protocol there is no
+// subscribe command to carry this information, so it travels in the
+// request itself as the `channels` query param.
+//
+// There is currently no message recovery for these transports: a
+// reconnecting client simply re-bootstraps and receives new
+// publications from that point on, it cannot replay what it missed
+// while disconnected.
+type BootstrapRequest struct {
+	// Channels requested for subscription, in bootstrap order.
+	Channels []string
+}
+
+// ParseBootstrapRequest extracts the channel list from an incoming
+// unidirectional transport request.
+func ParseBootstrapRequest(r *http.Request) BootstrapRequest {
+	query := r.URL.Query()
+
+	var channels []string
+	if raw := query.Get(channelsQueryParam); raw != "" {
+		for _, ch := range strings.Split(raw, ",") {
+			ch = strings.TrimSpace(ch)
+			if ch != "" {
+				channels = append(channels, ch)
+			}
+		}
+	}
+
+	return BootstrapRequest{Channels: channels}
+}